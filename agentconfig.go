@@ -0,0 +1,638 @@
+package goAgent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of an agent entry that only exist in configuration files. They are
+// resolved while loading and never reach the Agent.
+const (
+	extendsKey  = "extends"  // name of the entry whose settings this one inherits
+	abstractKey = "abstract" // true for entries that are only used as a base
+)
+
+// AgentConfigNames lists the file names FindAgentConfig looks for, in order.
+var AgentConfigNames = []string{"agents.json", "agents.yaml", "agents.yml", "agents.toml"}
+
+// FieldError is a problem with a single value of a configuration file.
+// Path names the value, e.g. Planner.provider.baseurl.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ConfigError lists every problem found in an agent configuration file.
+type ConfigError struct {
+	Source   string
+	Problems []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid agent config %s:", e.Source)
+	for _, problem := range e.Problems {
+		sb.WriteString("\n  " + problem.Error())
+	}
+	return sb.String()
+}
+
+// FindAgentConfig returns the first of AgentConfigNames found in ConfigPath.
+func FindAgentConfig() (string, bool) {
+	for _, name := range AgentConfigNames {
+		if path, ok := FindConfig(name); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ConfigFormat returns the format of a configuration file from its extension:
+// "yaml", "toml" or, for anything else, "json".
+func ConfigFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// LoadAgentsFile reads the agents defined in path, in the format given by its extension.
+func LoadAgentsFile(path string) (map[string]*Agent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agents: %w", err)
+	}
+	return ParseAgents(data, ConfigFormat(path), path)
+}
+
+// ParseAgents decodes an agent configuration in format ("json", "yaml" or
+// "toml"); source names it in errors. Agents are keyed by name:
+//
+//   - ${VAR} in any string is replaced with the environment variable VAR, and
+//     ${VAR:-default} falls back to default when VAR is unset or empty. An unset
+//     variable without a default is an error; $${ gives a literal ${.
+//   - "extends": "Base" starts the agent from a copy of the Base entry. Objects
+//     are merged key by key, anything else set by the agent replaces the base
+//     value. The name is not inherited and defaults to the entry's key.
+//   - "abstract": true marks an entry that only serves as a base; it is not
+//     returned as an agent.
+//
+// Unknown fields, values of the wrong type and settings that cannot work, such
+// as a missing model name, are all reported together in a *ConfigError.
+func ParseAgents(data []byte, format, source string) (map[string]*Agent, error) {
+	raw, err := decodeAgentConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agents from %s: %w", source, err)
+	}
+
+	var problems []FieldError
+	names := sortedKeys(raw)
+	entries := make(map[string]map[string]interface{}, len(raw))
+	templates := make(map[string]map[string]interface{}, len(raw)) // entries before expandEnv
+	for _, name := range names {
+		entry, ok := raw[name].(map[string]interface{})
+		if !ok {
+			problems = append(problems, FieldError{name, "expected object, got " + jsonTypeName(raw[name])})
+			continue
+		}
+		if value, set := entry[abstractKey]; set {
+			if _, ok = value.(bool); !ok {
+				problems = append(problems, FieldError{name + "." + abstractKey, "expected boolean, got " + describeValue(value)})
+			}
+		}
+		templates[name] = copyConfig(entry).(map[string]interface{})
+		entries[name] = expandEnv(name, entry, &problems).(map[string]interface{})
+	}
+
+	resolved := make(map[string]map[string]interface{}, len(entries))
+	resolvedTemplates := make(map[string]map[string]interface{}, len(templates))
+	agents := make(map[string]*Agent, len(entries))
+	for _, name := range names {
+		if entries[name] == nil {
+			continue
+		}
+		entry, ok := resolveAgentEntry(name, entries, resolved, nil, &problems)
+		if !ok {
+			continue
+		}
+		if abstract, _ := entry[abstractKey].(bool); abstract {
+			continue
+		}
+		entry = copyConfig(entry).(map[string]interface{})
+		delete(entry, abstractKey)
+		before := len(problems)
+		checkValue(name, entry, reflect.TypeOf(Agent{}), &problems)
+		if len(problems) > before {
+			continue // the agent cannot be decoded
+		}
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load agent %s from %s: %w", name, source, err)
+		}
+		var agent Agent
+		if err = json.Unmarshal(encoded, &agent); err != nil {
+			return nil, fmt.Errorf("failed to load agent %s from %s: %w", name, source, err)
+		}
+		agent.validate(name, &problems)
+		var ignored []FieldError // already reported for the expanded entries
+		if template, ok := resolveAgentEntry(name, templates, resolvedTemplates, nil, &ignored); ok {
+			agent.env = collectEnv(template, entry, nil, nil)
+		}
+		agents[name] = &agent
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Source: source, Problems: problems}
+	}
+	return agents, nil
+}
+
+// decodeAgentConfig parses data into a generic object of agents by name. YAML and TOML are
+// converted to JSON first so that every format is checked the same way.
+func decodeAgentConfig(data []byte, format string) (map[string]interface{}, error) {
+	switch format {
+	case "yaml":
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			value = map[string]interface{}{}
+		}
+		converted, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported yaml: %w", err)
+		}
+		data = converted
+	case "toml":
+		value := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported toml: %w", err)
+		}
+		data = converted
+	case "json":
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			line, column := lineColumn(data, syntaxError.Offset)
+			return nil, fmt.Errorf("line %d, column %d: %w", line, column, err)
+		}
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			return nil, fmt.Errorf("expected an object of agents by name, got %s", typeError.Value)
+		}
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// lineColumn converts the offset of a json.SyntaxError, which counts the
+// offending byte, to its 1-based line and column in data.
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, len(before) - bytes.LastIndexByte(before, '\n') - 1
+}
+
+var envPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references in the strings of value.
+func expandEnv(path string, value interface{}, problems *[]FieldError) interface{} {
+	switch v := value.(type) {
+	case string:
+		return envPattern.ReplaceAllStringFunc(v, func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+			groups := envPattern.FindStringSubmatch(match)
+			if env, ok := os.LookupEnv(groups[1]); ok && env != "" {
+				return env
+			}
+			if strings.Contains(match, ":-") {
+				return groups[2]
+			}
+			if _, ok := os.LookupEnv(groups[1]); ok {
+				return ""
+			}
+			*problems = append(*problems, FieldError{path, fmt.Sprintf("environment variable %s is not set", groups[1])})
+			return ""
+		})
+	case map[string]interface{}:
+		keys := sortedKeys(v)
+		for _, key := range keys {
+			v[key] = expandEnv(path+"."+key, v[key], problems)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = expandEnv(fmt.Sprintf("%s[%d]", path, i), v[i], problems)
+		}
+	}
+	return value
+}
+
+// envValue is a string of an agent that was read from a ${VAR} template.
+type envValue struct {
+	path     []string // JSON keys, and indexes of arrays, from the agent down
+	template string
+	value    string
+}
+
+// collectEnv lists the strings of expanded that differ from template, the
+// same entry before expandEnv.
+func collectEnv(template, expanded interface{}, path []string, found []envValue) []envValue {
+	switch v := expanded.(type) {
+	case string:
+		if raw, ok := template.(string); ok && raw != v {
+			found = append(found, envValue{path: path, template: raw, value: v})
+		}
+	case map[string]interface{}:
+		raw, _ := template.(map[string]interface{})
+		for _, key := range sortedKeys(v) {
+			found = collectEnv(raw[key], v[key], append(path[:len(path):len(path)], key), found)
+		}
+	case []interface{}:
+		raw, _ := template.([]interface{})
+		for i := range v {
+			if i < len(raw) {
+				found = collectEnv(raw[i], v[i], append(path[:len(path):len(path)], strconv.Itoa(i)), found)
+			}
+		}
+	}
+	return found
+}
+
+// withEnvTemplates returns the agent with the ${VAR} templates it was loaded
+// from in place of their values, so saving it does not write out secrets read
+// from the environment. Values changed since loading are kept.
+func (a *Agent) withEnvTemplates() *Agent {
+	if len(a.env) == 0 {
+		return a
+	}
+	clone := a.Clone()
+	for _, env := range a.env {
+		field, ok := envField(reflect.ValueOf(clone).Elem(), env.path)
+		if ok && field.String() == env.value {
+			field.SetString(env.template)
+		}
+	}
+	return clone
+}
+
+// envField follows path through the structs, pointers and slices of value to
+// a settable string.
+func envField(value reflect.Value, path []string) (reflect.Value, bool) {
+	for _, key := range path {
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			field, ok := structField(value.Type(), key)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value = value.FieldByIndex(field.Index)
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= value.Len() {
+				return reflect.Value{}, false
+			}
+			value = value.Index(i)
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return value, value.Kind() == reflect.String && value.CanSet()
+}
+
+// resolveAgentEntry returns the entry name with everything it extends merged in.
+// chain holds the entries being resolved, to report cycles.
+func resolveAgentEntry(name string, entries, resolved map[string]map[string]interface{}, chain []string, problems *[]FieldError) (map[string]interface{}, bool) {
+	if entry, ok := resolved[name]; ok {
+		return entry, entry != nil // nil once it failed, so it is reported only once
+	}
+	entry := entries[name]
+	baseValue, hasBase := entry[extendsKey]
+	if !hasBase {
+		resolved[name] = withName(name, entry)
+		return resolved[name], true
+	}
+
+	resolved[name] = nil
+	path := name + "." + extendsKey
+	base, ok := baseValue.(string)
+	switch {
+	case !ok:
+		*problems = append(*problems, FieldError{path, "expected string, got " + jsonTypeName(baseValue)})
+		return nil, false
+	case entries[base] == nil:
+		*problems = append(*problems, FieldError{path, fmt.Sprintf("unknown agent %q", base)})
+		return nil, false
+	}
+	chain = append(chain, name)
+	for i, seen := range chain {
+		if seen == base {
+			cycle := append(append([]string(nil), chain[i:]...), base)
+			*problems = append(*problems, FieldError{path, "inheritance cycle " + strings.Join(cycle, " -> ")})
+			return nil, false
+		}
+	}
+	parent, ok := resolveAgentEntry(base, entries, resolved, chain, problems)
+	if !ok {
+		return nil, false
+	}
+
+	own := make(map[string]interface{}, len(entry))
+	for key, value := range entry {
+		if key != extendsKey {
+			own[key] = value
+		}
+	}
+	merged := mergeConfig(parent, own).(map[string]interface{})
+	delete(merged, "name")
+	if _, ok = own[abstractKey]; !ok {
+		delete(merged, abstractKey)
+	}
+	for key, value := range own {
+		if key == "name" {
+			merged[key] = value
+		}
+	}
+	resolved[name] = withName(name, merged)
+	return resolved[name], true
+}
+
+// withName defaults the name of an entry to its key.
+func withName(name string, entry map[string]interface{}) map[string]interface{} {
+	if value, ok := entry["name"]; !ok || value == "" || value == nil {
+		entry["name"] = name
+	}
+	return entry
+}
+
+// mergeConfig returns a copy of base with override applied: objects are merged
+// recursively, any other override value replaces the base value.
+func mergeConfig(base, override interface{}) interface{} {
+	baseMap, baseOk := base.(map[string]interface{})
+	overrideMap, overrideOk := override.(map[string]interface{})
+	if !baseOk || !overrideOk {
+		return copyConfig(override)
+	}
+	merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		merged[key] = copyConfig(value)
+	}
+	for key, value := range overrideMap {
+		if existing, ok := merged[key]; ok {
+			merged[key] = mergeConfig(existing, value)
+		} else {
+			merged[key] = copyConfig(value)
+		}
+	}
+	return merged
+}
+
+func copyConfig(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return mergeConfig(map[string]interface{}{}, v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = copyConfig(item)
+		}
+		return items
+	}
+	return value
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// checkValue reports every place where value, decoded with UseNumber, does not
+// fit the Go type t: unknown fields and values of the wrong type.
+func checkValue(path string, value interface{}, t reflect.Type, problems *[]FieldError) {
+	if value == nil {
+		return // encoding/json leaves the field as it is
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			err = json.Unmarshal(encoded, reflect.New(t).Interface())
+		}
+		if err != nil {
+			*problems = append(*problems, FieldError{path, err.Error()})
+		}
+		return
+	}
+
+	mismatch := func(expected string) {
+		*problems = append(*problems, FieldError{path, fmt.Sprintf("expected %s, got %s", expected, describeValue(value))})
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkValue(path, value, t.Elem(), problems)
+	case reflect.Interface:
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("object")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			field, ok := structField(t, key)
+			if !ok {
+				*problems = append(*problems, FieldError{path + "." + key, "unknown field"})
+				continue
+			}
+			checkValue(path+"."+key, object[key], field.Type, problems)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("object")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			checkValue(path+"."+key, object[key], t.Elem(), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			mismatch("array")
+			return
+		}
+		for i, item := range items {
+			checkValue(fmt.Sprintf("%s[%d]", path, i), item, t.Elem(), problems)
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			mismatch("string")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			mismatch("boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := value.(json.Number)
+		if !ok {
+			mismatch("integer")
+		} else if _, err := strconv.ParseInt(number.String(), 10, 64); err != nil {
+			mismatch("integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			mismatch("number")
+		}
+	}
+}
+
+// structField finds the field of t that encoding/json decodes key into,
+// preferring an exact match of the name over a case-insensitive one.
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return field, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = field, true
+		}
+	}
+	return fold, found
+}
+
+// describeValue names the JSON type of value, with the value itself for scalars.
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case json.Number:
+		return "number " + v.String()
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	}
+	return jsonTypeName(value)
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validate reports settings of the agent loaded as name that cannot work.
+func (a *Agent) validate(name string, problems *[]FieldError) {
+	add := func(field, message string) {
+		*problems = append(*problems, FieldError{name + "." + field, message})
+	}
+	if a.Model.Name == "" {
+		add("model.name", "is required")
+	}
+	if a.Model.ContextWindow < 0 {
+		add("model.contextWindow", "must not be negative")
+	}
+	if a.Model.EmbeddingLength < 0 {
+		add("model.embeddingLength", "must not be negative")
+	}
+	if options := a.Model.Options; options != nil {
+		if options.Temperature != nil && *options.Temperature < 0 {
+			add("model.options.temperature", "must not be negative")
+		}
+		if options.TopP != nil && (*options.TopP < 0 || *options.TopP > 1) {
+			add("model.options.topP", "must be between 0 and 1")
+		}
+		if options.TopK != nil && *options.TopK < 0 {
+			add("model.options.topK", "must not be negative")
+		}
+		if options.NumCtx != nil && *options.NumCtx < 0 {
+			add("model.options.numCtx", "must not be negative")
+		}
+	}
+
+	if a.Provider == nil {
+		add("provider", "is required")
+	} else {
+		if a.Provider.BaseUrl == "" {
+			add("provider.baseurl", "is required")
+		}
+		if port := a.Provider.Port; port != "" {
+			if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+				add("provider.port", fmt.Sprintf("%q is not a port number", port))
+			}
+		}
+		if backendType := a.Provider.Type; backendType != "" && !slices.Contains(Backends(), backendType) {
+			add("provider.type", fmt.Sprintf("unknown provider type %q (available: %v)", backendType, Backends()))
+		}
+	}
+
+	if retry := a.Retry; retry != nil {
+		if retry.MaxAttempts < 0 {
+			add("retry.maxAttempts", "must not be negative")
+		}
+		if retry.InitialBackoff < 0 {
+			add("retry.initialBackoff", "must not be negative")
+		}
+		if retry.MaxBackoff < 0 {
+			add("retry.maxBackoff", "must not be negative")
+		}
+		if retry.Multiplier < 0 {
+			add("retry.multiplier", "must not be negative")
+		}
+		if retry.Jitter < 0 || retry.Jitter > 1 {
+			add("retry.jitter", "must be between 0 and 1")
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const templatedAgents = `{
+  "base": {"abstract": true, "model": {"name": "m"}, "provider": {"type": "ollama", "baseurl": "${HOST:-http://localhost}", "apiKey": "${SECRET}"}},
+  "Planner": {"extends": "base"},
+  "Edited": {"extends": "base"}
+}`
+
+func TestSaveAgentsKeepsEnvTemplates(t *testing.T) {
+	t.Setenv("SECRET", "sk-123")
+	agents, err := ParseAgents([]byte(templatedAgents), "json", "agents.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := agents["Planner"].Provider.ApiKey; got != "sk-123" {
+		t.Fatalf("apiKey = %q, want the expanded value", got)
+	}
+	agents["Edited"].Provider.ApiKey = "sk-edited"
+
+	path := filepath.Join(t.TempDir(), "agents.json")
+	if err = SaveAgents(path, agents); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	if strings.Contains(saved, "sk-123") {
+		t.Fatalf("saved file contains the secret:\n%s", saved)
+	}
+	for _, want := range []string{`"apiKey": "${SECRET}"`, `"baseurl": "${HOST:-http://localhost}"`, `"apiKey": "sk-edited"`} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("saved file lacks %s:\n%s", want, saved)
+		}
+	}
+	if agents["Planner"].Provider.ApiKey != "sk-123" {
+		t.Fatal("saving changed the loaded agent")
+	}
+
+	reloaded, err := LoadAgentsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded["Planner"].Provider.ApiKey; got != "sk-123" {
+		t.Fatalf("reloaded apiKey = %q", got)
+	}
+}
+
+func TestSaveAgentMeshKeepsEnvTemplates(t *testing.T) {
+	t.Setenv("SECRET", "sk-123")
+	dir := t.TempDir()
+	source := filepath.Join(dir, "agents.json")
+	if err := os.WriteFile(source, []byte(templatedAgents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mesh := NewAgentMesh(source)
+	if err := mesh.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "saved.json")
+	if err := SaveAgentMesh(target, mesh); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-123") || !strings.Contains(string(data), "${SECRET}") {
+		t.Fatalf("mesh saved the secret instead of its template:\n%s", data)
+	}
+}
+
+func TestParseAgentsExtends(t *testing.T) {
+	agents, err := ParseAgents([]byte(`{
+  "base": {"abstract": true, "model": {"name": "m", "options": {"temperature": 0.2, "topK": 40}}, "provider": {"type": "ollama", "baseurl": "http://localhost"}},
+  "Planner": {"extends": "base", "name": "planner", "model": {"options": {"temperature": 0.9}}, "systemPrompt": "plan"},
+  "Critic": {"extends": "Planner", "model": {"name": "big"}}
+}`), "json", "agents.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agents["base"]; ok || len(agents) != 2 {
+		t.Fatalf("got agents %v, want only Planner and Critic", sortedAgentNames(agents))
+	}
+	planner, critic := agents["Planner"], agents["Critic"]
+	if planner.Name != "planner" || planner.Model.Name != "m" || *planner.Model.Options.Temperature != 0.9 || *planner.Model.Options.TopK != 40 {
+		t.Fatalf("Planner was not merged with base: %+v %+v", planner, planner.Model.Options)
+	}
+	if critic.Name != "Critic" || critic.Model.Name != "big" || critic.SystemPrompt != "plan" || *critic.Model.Options.Temperature != 0.9 {
+		t.Fatalf("Critic was not merged with Planner: %+v %+v", critic, critic.Model.Options)
+	}
+	if critic.Provider == planner.Provider || critic.Model.Options == planner.Model.Options {
+		t.Fatal("agents extending the same base share its values")
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("SET", "value")
+	t.Setenv("EMPTY", "")
+	tests := []struct {
+		input   string
+		want    string
+		problem bool
+	}{
+		{input: "${SET}", want: "value"},
+		{input: "http://${SET}:8080/${SET}", want: "http://value:8080/value"},
+		{input: "${SET:-default}", want: "value"},
+		{input: "${UNSET:-default}", want: "default"},
+		{input: "${EMPTY:-default}", want: "default"},
+		{input: "${UNSET:-}", want: ""},
+		{input: "${EMPTY}", want: ""},
+		{input: "$${SET}", want: "${SET}"},
+		{input: "$SET and ${ SET }", want: "$SET and ${ SET }"},
+		{input: "${UNSET}", want: "", problem: true},
+	}
+	for _, test := range tests {
+		var problems []FieldError
+		got := expandEnv("agent", test.input, &problems)
+		if got != test.want || (len(problems) > 0) != test.problem {
+			t.Errorf("expandEnv(%q) = %q with problems %v, want %q", test.input, got, problems, test.want)
+		}
+	}
+
+	var problems []FieldError
+	value := map[string]interface{}{"list": []interface{}{"${SET}", json.Number("1")}, "object": map[string]interface{}{"key": "${UNSET}"}}
+	expandEnv("agent", value, &problems)
+	if value["list"].([]interface{})[0] != "value" || len(problems) != 1 || problems[0].Path != "agent.object.key" {
+		t.Fatalf("got %v with problems %v", value, problems)
+	}
+}
+
+func TestParseAgentsFormats(t *testing.T) {
+	want := map[string]*Agent{"Planner": {
+		Name:     "Planner",
+		Model:    Model{Name: "m", ContextWindow: 8192, Options: &ModelOptions{Temperature: Ptr(0.5), Stop: []string{"END"}}},
+		Provider: &Provider{Type: "ollama", BaseUrl: "http://localhost", Port: "11434"},
+	}}
+	configs := map[string]string{
+		"json": `{"Planner": {"model": {"name": "m", "contextWindow": 8192, "options": {"temperature": 0.5, "stop": ["END"]}}, "provider": {"type": "ollama", "baseurl": "http://localhost", "port": "11434"}}}`,
+		"yaml": `
+Planner:
+  model:
+    name: m
+    contextWindow: 8192
+    options:
+      temperature: 0.5
+      stop: [END]
+  provider:
+    type: ollama
+    baseurl: http://localhost
+    port: "11434"
+`,
+		"toml": `
+[Planner.model]
+name = "m"
+contextWindow = 8192
+options = { temperature = 0.5, stop = ["END"] }
+
+[Planner.provider]
+type = "ollama"
+baseurl = "http://localhost"
+port = "11434"
+`,
+	}
+	for format, config := range configs {
+		agents, err := ParseAgents([]byte(config), format, "agents."+format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, _ := json.Marshal(agents)
+		expected, _ := json.Marshal(want)
+		if string(got) != string(expected) {
+			t.Errorf("%s decoded to %s, want %s", format, got, expected)
+		}
+	}
+
+	for _, test := range []struct{ format, config, want string }{
+		{"json", "{\n  \"Planner\": {,}\n}", "line 2, column 15"},
+		{"json", `["Planner"]`, "expected an object of agents by name"},
+		{"yaml", "Planner: [", "yaml"},
+		{"toml", "Planner = ", "toml"},
+		{"ini", "", `unknown config format "ini"`},
+	} {
+		_, err := ParseAgents([]byte(test.config), test.format, "agents")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s %q gave %v, want %q", test.format, test.config, err, test.want)
+		}
+	}
+}
+
+func TestParseAgentsReportsEveryProblem(t *testing.T) {
+	const provider = `"provider": {"type": "ollama", "baseurl": "http://localhost"}`
+	tests := []struct {
+		name     string
+		config   string
+		problems []string
+	}{
+		{
+			name:     "cycle",
+			config:   `{"A": {"extends": "B"}, "B": {"extends": "C"}, "C": {"extends": "A"}}`,
+			problems: []string{"C.extends: inheritance cycle A -> B -> C -> A"},
+		},
+		{
+			name:     "self",
+			config:   `{"A": {"extends": "A"}}`,
+			problems: []string{"A.extends: inheritance cycle A -> A"},
+		},
+		{
+			name:     "unknown base",
+			config:   `{"A": {"extends": "Missing"}, "B": {"extends": 1}}`,
+			problems: []string{`A.extends: unknown agent "Missing"`, "B.extends: expected string, got number"},
+		},
+		{
+			name:   "fields",
+			config: `{"A": {"model": {"name": "m", "contextWindow": "big", "reasoning": 1}, "colour": "red", ` + provider + `}, "B": [], "C": {"abstract": "yes"}}`,
+			problems: []string{
+				"B: expected object, got array",
+				`C.abstract: expected boolean, got string "yes"`,
+				"A.colour: unknown field",
+				`A.model.contextWindow: expected integer, got string "big"`,
+				"A.model.reasoning: expected boolean, got number 1",
+				"C.model.name: is required",
+				"C.provider: is required",
+			},
+		},
+		{
+			name:     "durations",
+			config:   `{"A": {"model": {"name": "m"}, "retry": {"maxBackoff": "soon"}, ` + provider + `}}`,
+			problems: []string{"A.retry.maxBackoff: "},
+		},
+		{
+			name:     "environment",
+			config:   `{"A": {"model": {"name": "${UNSET_MODEL}"}, ` + provider + `}}`,
+			problems: []string{"A.model.name: environment variable UNSET_MODEL is not set", "A.model.name: is required"},
+		},
+		{
+			name: "validate",
+			config: `{"A": {"model": {"contextWindow": -1, "embeddingLength": -1, "options": {"temperature": -1, "topP": 2, "topK": -1, "numCtx": -1}},
+			          "provider": {"type": "carrier-pigeon", "port": "99999"},
+			          "retry": {"maxAttempts": -1, "initialBackoff": "-1s", "maxBackoff": "-1s", "multiplier": -1, "jitter": 2}},
+			          "B": {"model": {"name": "m"}}}`,
+			problems: []string{
+				"A.model.name: is required",
+				"A.model.contextWindow: must not be negative",
+				"A.model.embeddingLength: must not be negative",
+				"A.model.options.temperature: must not be negative",
+				"A.model.options.topP: must be between 0 and 1",
+				"A.model.options.topK: must not be negative",
+				"A.model.options.numCtx: must not be negative",
+				"A.provider.baseurl: is required",
+				`A.provider.port: "99999" is not a port number`,
+				`A.provider.type: unknown provider type "carrier-pigeon"`,
+				"A.retry.maxAttempts: must not be negative",
+				"A.retry.initialBackoff: must not be negative",
+				"A.retry.maxBackoff: must not be negative",
+				"A.retry.multiplier: must not be negative",
+				"A.retry.jitter: must be between 0 and 1",
+				"B.provider: is required",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseAgents([]byte(test.config), "json", "agents.json")
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("got %v, want a ConfigError", err)
+			}
+			if configErr.Source != "agents.json" || len(configErr.Problems) != len(test.problems) {
+				t.Fatalf("got %v, want %d problems", err, len(test.problems))
+			}
+			for i, want := range test.problems {
+				if got := configErr.Problems[i].Error(); !strings.HasPrefix(got, want) {
+					t.Errorf("problem %d is %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func sortedAgentNames(agents map[string]*Agent) []string {
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
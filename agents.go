@@ -1,10 +1,15 @@
 package goAgent
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"runtime/debug"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -12,9 +17,14 @@ var client = &http.Client{
 	Timeout: 10 * time.Minute, // Set a timeout for requests
 }
 
-var EmbeddingAgent *Agent
-var SummaryAgent *Agent
-var PlannerAgent *Agent
+// EmbeddingAgent, SummaryAgent and PlannerAgent are the agents DefaultRuntime uses.
+//
+// Deprecated: pass a Runtime instead; see NewRuntime.
+var (
+	EmbeddingAgent *Agent
+	SummaryAgent   *Agent
+	PlannerAgent   *Agent
+)
 
 type Agent struct {
 	Name         string        `json:"name"`
@@ -24,6 +34,9 @@ type Agent struct {
 	Language     string        `json:"language,omitempty"`
 	SystemPrompt string        `json:"systemPrompt,omitempty"`
 	Tools        *ToolRegistry `json:"tools,omitempty"`
+	Retry        *RetryPolicy  `json:"retry,omitempty"`
+
+	env []envValue // strings read from ${VAR} templates; see ParseAgents
 }
 
 func (a *Agent) Clone() *Agent {
@@ -32,17 +45,18 @@ func (a *Agent) Clone() *Agent {
 		Name:        a.Name,
 		Model:       a.Model,
 		Description: a.Description,
+		env:         a.env,
+	}
+	agentCopy.Model.Options = a.Model.Options.Clone()
+	if a.Model.Capabilities != nil {
+		agentCopy.Model.Capabilities = append([]string(nil), a.Model.Capabilities...)
 	}
 	if a.Provider != nil {
-		agentCopy.Provider = &Provider{
-			BaseUrl:           a.Provider.BaseUrl,
-			Port:              a.Provider.Port,
-			GenerateEndpoint:  a.Provider.GenerateEndpoint,
-			ChatEndpoint:      a.Provider.ChatEndpoint,
-			EmbeddingEndpoint: a.Provider.EmbeddingEndpoint,
-			TokenizeEndpoint:  a.Provider.TokenizeEndpoint,
-			ApiKey:            a.Provider.ApiKey,
-		}
+		agentCopy.Provider = a.Provider.Clone()
+	}
+	if a.Retry != nil {
+		retry := *a.Retry
+		agentCopy.Retry = &retry
 	}
 	if a.Language != "" {
 		agentCopy.Language = a.Language
@@ -51,7 +65,7 @@ func (a *Agent) Clone() *Agent {
 		agentCopy.SystemPrompt = a.SystemPrompt
 	}
 	if a.Tools != nil {
-		agentCopy.Tools = a.Tools // Provide a shallow copy of the ToolRegistry
+		agentCopy.Tools = a.Tools.Clone()
 	} else {
 		agentCopy.Tools = NewToolRegistry()
 	}
@@ -71,19 +85,23 @@ func (a *Agent) WithPort(port string) *Agent {
 // clearTools clears the agent's tools and returns the previous tools.
 // It returns an empty slice if no tools were set.
 func (a *Agent) ClearTools() *ToolRegistry {
-	return a.Tools.Clear()
+	return a.GetTools().Clear()
 }
 
 // RegisterTools sets the tools for the agent.
 // It appends valid tools to the agent's tool list, ignoring nil or invalid tools.
 func (a *Agent) RegisterTools(tools ...*Tool) {
-	if a.Tools == nil {
-		a.Tools = NewToolRegistry()
-	}
-	a.Tools.RegisterTools(tools...)
+	a.GetTools().RegisterTools(tools...)
 }
 
+// agentToolsMu guards the creation of Agent.Tools by GetTools.
+var agentToolsMu sync.Mutex
+
+// GetTools returns the agent's tools, giving it an empty registry first if it
+// has none. Concurrent chats sharing the agent get the same registry.
 func (a *Agent) GetTools() *ToolRegistry {
+	agentToolsMu.Lock()
+	defer agentToolsMu.Unlock()
 	if a.Tools == nil {
 		a.Tools = NewToolRegistry()
 	}
@@ -91,78 +109,70 @@ func (a *Agent) GetTools() *ToolRegistry {
 }
 
 func (a *Agent) GetToolMap() map[string]*Tool {
-	if a.Tools == nil {
-		a.Tools = NewToolRegistry()
-	}
-	return a.Tools.GetToolMap()
+	return a.GetTools().GetToolMap()
 }
 
 func (a *Agent) SwapRegistry(registry *ToolRegistry) *ToolRegistry {
-	if a.Tools == nil {
-		a.Tools = NewToolRegistry()
-	}
-	return a.Tools.Swap(registry)
+	return a.GetTools().Swap(registry)
 }
 
 // GetTools retrieves tools by their names from the agent.
 // It returns an error if any of the specified tools are not found.
 func (a *Agent) GetToolsByName(name ...string) (*ToolRegistry, error) {
-	if a.Tools == nil {
-		a.Tools = NewToolRegistry()
-	}
-	registry := a.Tools.GetToolsByName(name...)
-	if len(registry.Tools) == 0 {
+	registry := a.GetTools().GetToolsByName(name...)
+	if registry.Len() == 0 {
 		return nil, fmt.Errorf("no tools found for names: %v", name)
 	}
 	return registry, nil
 }
 
-type AgentMesh struct {
-	Agents map[string]*Agent `json:"agents"`
-}
-
 type Model struct {
-	Name          string `json:"name"`
-	ContextWindow int    `json:"contextWindow"`
-	Reasoning     bool   `json:"reasoning,omitempty"`
+	Name            string        `json:"name"`
+	ContextWindow   int           `json:"contextWindow"`
+	EmbeddingLength int           `json:"embeddingLength,omitempty"`
+	Reasoning       bool          `json:"reasoning,omitempty"`
+	Capabilities    []string      `json:"capabilities,omitempty"`
+	Options         *ModelOptions `json:"options,omitempty"`
 }
 
 type Provider struct {
+	Type              string `json:"type,omitempty"`
 	BaseUrl           string `json:"baseurl"`
 	Port              string `json:"port,omitempty"`
 	GenerateEndpoint  string `json:"generateEndpoint"`
 	ChatEndpoint      string `json:"chatEndpoint"`
 	EmbeddingEndpoint string `json:"embeddingEndpoint"`
 	TokenizeEndpoint  string `json:"tokenizeEndpoint,omitempty"`
+	ModelsEndpoint    string `json:"modelsEndpoint,omitempty"`
 	ApiKey            string `json:"apiKey"`
 }
 
-// GetChatUrl constructs the chat URL for the provider.
-func (p *Provider) GetChatUrl() string {
-	if p.Port != "" {
-		return fmt.Sprintf("%s:%s%s", p.BaseUrl, p.Port, p.ChatEndpoint)
-	}
-	return fmt.Sprintf("%s%s", p.BaseUrl, p.ChatEndpoint)
+// Clone returns a copy of the provider.
+func (p *Provider) Clone() *Provider {
+	providerCopy := *p
+	return &providerCopy
 }
 
-// getGenerateUrl constructs the generate URL for the provider.
-func (p *Provider) getGenerateUrl() string {
+// endpointUrl joins the provider's base url and port with endpoint.
+// If endpoint is empty the backend's fallback endpoint is used instead.
+func (p *Provider) endpointUrl(endpoint, fallback string) string {
+	if endpoint == "" {
+		endpoint = fallback
+	}
 	if p.Port != "" {
-		return fmt.Sprintf("%s:%s%s", p.BaseUrl, p.Port, p.GenerateEndpoint)
+		return fmt.Sprintf("%s:%s%s", p.BaseUrl, p.Port, endpoint)
 	}
-	return fmt.Sprintf("%s%s", p.BaseUrl, p.GenerateEndpoint)
+	return fmt.Sprintf("%s%s", p.BaseUrl, endpoint)
 }
 
-// getEmbeddingUrl constructs the embedding URL for the provider.
-func (p *Provider) getEmbeddingUrl() string {
-	if p.Port != "" {
-		return fmt.Sprintf("%s:%s%s", p.BaseUrl, p.Port, p.EmbeddingEndpoint)
-	}
-	return fmt.Sprintf("%s%s", p.BaseUrl, p.EmbeddingEndpoint)
+// GetChatUrl constructs the chat URL for the provider.
+func (p *Provider) GetChatUrl() string {
+	return p.endpointUrl(p.ChatEndpoint, "")
 }
 
 func ProvideOllama() *Provider {
 	return &Provider{
+		Type:              BackendOllama,
 		BaseUrl:           "http://localhost",
 		Port:              "11434",
 		GenerateEndpoint:  "/api/generate",
@@ -171,10 +181,22 @@ func ProvideOllama() *Provider {
 	}
 }
 
+// LoadAgents reads the agents defined in file into agents, in the format given
+// by the file's extension. See ParseAgents for what the file may contain.
 func LoadAgents(file *os.File, agents *map[string]*Agent) error {
-	bind := BindJSON(file, agents)
-	if bind != nil {
-		return fmt.Errorf("failed to load agents from %s", file.Name())
+	data, err := ReadJSONFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to load agents from %s: %w", file.Name(), err)
+	}
+	loaded, err := ParseAgents(data, ConfigFormat(file.Name()), file.Name())
+	if err != nil {
+		return err
+	}
+	if *agents == nil {
+		*agents = make(map[string]*Agent, len(loaded))
+	}
+	for name, agent := range loaded {
+		(*agents)[name] = agent
 	}
 	return nil
 }
@@ -209,10 +231,22 @@ type EmbeddedContent struct {
 }
 
 func (a *Agent) Embed(content string) ([]*EmbeddedContent, error) {
+	return a.EmbedContext(context.Background(), content)
+}
+
+// EmbedContext embeds content chunk by chunk until ctx is cancelled.
+// On cancellation the chunks embedded so far are returned along with the context error.
+func (a *Agent) EmbedContext(ctx context.Context, content string) ([]*EmbeddedContent, error) {
 	embeddingContents := make([]*EmbeddedContent, 0)
 	for _, chunk := range ChunkByTokens(content, a.ContextPortion(100)) {
-		embeddedContent, err := a.EmbedChunk(chunk)
+		if err := ctx.Err(); err != nil {
+			return embeddingContents, err
+		}
+		embeddedContent, err := a.EmbedChunkContext(ctx, chunk)
 		if err != nil {
+			if ctx.Err() != nil {
+				return embeddingContents, ctx.Err()
+			}
 			return nil, fmt.Errorf("error embedding chunk: %w", err)
 		}
 		if embeddedContent != nil {
@@ -224,43 +258,29 @@ func (a *Agent) Embed(content string) ([]*EmbeddedContent, error) {
 }
 
 func (a *Agent) EmbedChunk(content string) (*EmbeddedContent, error) {
-	url := a.Provider.getEmbeddingUrl()
-
-	payload := map[string]interface{}{
-		"model":  a.Model.Name,
-		"prompt": content,
-	}
-
-	jsonData, err := marshalPayload(payload)
-	if err != nil {
-		return nil, err
-	}
+	return a.EmbedChunkContext(context.Background(), content)
+}
 
-	req, err := createPostRequest(url, jsonData)
+func (a *Agent) EmbedChunkContext(ctx context.Context, content string) (*EmbeddedContent, error) {
+	backend, err := a.Backend()
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := doRequest(req)
+	embedding, err := backend.Embed(ctx, a.Model, content)
 	if err != nil {
 		return nil, err
 	}
-
-	var result struct {
-		Embedding []float64 `json:"embedding"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("error decoding embedding: %w", err)
-	}
 	embeddingContents := &EmbeddedContent{
 		ID:        fmt.Sprintf("%s-%d", a.Model.Name, time.Now().UnixNano()),
 		Content:   content, // Assuming content is a single string
-		Embedding: result.Embedding,
+		Embedding: embedding,
 	}
 
 	return embeddingContents, nil
 }
-func (a *Agent) AsTool(functionCall func(map[string]interface{}, *Chat) (map[string]interface{}, error)) *Tool {
+
+func (a *Agent) AsTool(functionCall ToolHandler) *Tool {
 	tool := NewTool("agent", a.Name, a.Description, functionCall)
 	tool.Function.Parameters.AddProperty(
 		"message",
@@ -280,69 +300,256 @@ func (a *Agent) AsTool(functionCall func(map[string]interface{}, *Chat) (map[str
 }
 
 // SendMessage sends a message to the agent and returns the response.
+// With stream set the reply is streamed from the server and assembled before returning;
+// use SendMessageStream to observe it while it arrives.
 func (c *Chat) SendMessage(role, content string, stream bool) (*ChatResponse, error) {
-	url := c.Agent.Provider.GetChatUrl()
+	return c.sendMessage(context.Background(), role, content, callOptions{stream: stream})
+}
 
-	c.AddMessage(role, content)
-	payload := map[string]interface{}{
-		"model":      c.Agent.Model.Name,
-		"messages":   c.Messages,
-		"stream":     stream,
-		"tools":      c.Agent.Tools.GetTools(),
-		"keep_alive": -1,
-	}
+// SendMessageContext is SendMessage bound to ctx; cancelling ctx aborts the
+// model call and any tools it triggered.
+func (c *Chat) SendMessageContext(ctx context.Context, role, content string, stream bool) (*ChatResponse, error) {
+	return c.sendMessage(ctx, role, content, callOptions{stream: stream})
+}
+
+// SendMessageWithTools is SendMessageContext with tools offered to the model
+// and used for its calls in place of the agent's, for this call only. The
+// agent is left untouched, so chats sharing it can run at the same time.
+func (c *Chat) SendMessageWithTools(ctx context.Context, role, content string, tools *ToolRegistry, stream bool) (*ChatResponse, error) {
+	return c.sendMessage(ctx, role, content, callOptions{stream: stream, tools: tools})
+}
 
-	jsonData, err := marshalPayload(payload)
+// callOptions carries the per-call settings of sendMessage.
+type callOptions struct {
+	stream  bool
+	handler StreamHandler
+	format  map[string]interface{} // JSON Schema the reply must follow, if any
+	noTools bool                   // hide the agent's tools from the model
+	tools   *ToolRegistry          // replaces the agent's tools, if set
+}
+
+// begin makes ctx and the tools of call those of the chat until the returned
+// function is called.
+func (c *Chat) begin(ctx context.Context, call callOptions) func() {
+	previousCtx, previousTools := c.ctx, c.tools
+	c.ctx, c.tools = ctx, call.tools
+	return func() { c.ctx, c.tools = previousCtx, previousTools }
+}
+
+func (c *Chat) sendMessage(ctx context.Context, role, content string, call callOptions) (*ChatResponse, error) {
+	defer c.begin(ctx, call)()
+
+	c.AddMessage(role, content)
+	chatResponse, err := c.complete(ctx, call)
 	if err != nil {
 		return nil, err
 	}
+	c.RunTools(&chatResponse.Message)
+	return chatResponse, nil
+}
 
-	req, err := createPostRequest(url, jsonData)
+// complete sends the conversation as it stands and appends the model's reply,
+// tool calls included, to the chat.
+func (c *Chat) complete(ctx context.Context, call callOptions) (*ChatResponse, error) {
+	ctx = c.withRuntime(ctx)
+	backend, err := c.Agent.Backend()
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+	model := c.Agent.Model
+	model.Options = model.Options.Merge(c.Options)
+
+	var tools []*Tool
+	if !call.noTools {
+		tools = c.agentTools(call.tools).GetTools()
 	}
 
-	chatResponse, err := DecodeChatResponse(resp.Body)
+	chatResponse, err := backend.Chat(ctx, &ChatRequest{
+		Model:    model,
+		Messages: c.Messages,
+		Tools:    tools,
+		Format:   call.format,
+		Stream:   call.stream,
+		OnEvent:  call.handler,
+	})
 	if err != nil {
-		fmt.Println("decode error:", err)
 		return nil, err
 	}
+	if call.stream {
+		for _, toolCall := range chatResponse.Message.ToolCalls {
+			call.handler.emit(&StreamEvent{Type: StreamToolCall, ToolCall: toolCall})
+		}
+		call.handler.emit(&StreamEvent{Type: StreamDone, Response: chatResponse})
+	}
 
-	c.AddMessage(chatResponse.Message.Role, chatResponse.Message.Content)
-	c.RunTools(&chatResponse.Message)
+	reply := chatResponse.Message
+	reply.Time = time.Now()
+	c.Messages = append(c.Messages, &reply)
 	return chatResponse, nil
 }
 
-func (c *Chat) RunTools(message *Message) {
-	if len(message.ToolCalls) > 0 {
-		for i, _ := range message.ToolCalls {
-			toolCall, ok := message.ToolCalls[i]["function"].(map[string]interface{})
-			toolName, ok := toolCall["name"].(string)
-			if !ok {
-				fmt.Println("Tool name not found in tool call")
-				continue
+// Context returns the context of the request the chat is currently serving,
+// so tool handlers can honour cancellation. It is never nil.
+func (c *Chat) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// agentTools returns override, if set, or the agent's tools.
+func (c *Chat) agentTools(override *ToolRegistry) *ToolRegistry {
+	if override != nil {
+		return override
+	}
+	return c.Agent.GetTools()
+}
+
+// withRuntime returns ctx carrying the chat's runtime, if it has one.
+func (c *Chat) withRuntime(ctx context.Context) context.Context {
+	if c.Runtime == nil {
+		return ctx
+	}
+	return WithRuntime(ctx, c.Runtime)
+}
+
+// DefaultMaxParallelTools is how many tool calls from one reply run at once
+// when Chat.MaxParallelTools is unset.
+const DefaultMaxParallelTools = 4
+
+// RunTools executes the tool calls in message, up to MaxParallelTools at a
+// time, and appends one tool message per call to the chat, holding the call's
+// result or error, so the model sees what its tools returned on the next send.
+// The messages keep the order of the calls. Calls without an id are given one.
+// Calls needing approval are put to the chat's Approver one at a time before
+// any call runs. Calls that do not run because the chat's context is done
+// still get a tool message, holding the context's error, as providers reject
+// a conversation with unanswered tool calls. The appended messages are returned.
+func (c *Chat) RunTools(message *Message) []*Message {
+	ctx := c.Context()
+	limit := c.MaxParallelTools
+	if limit <= 0 {
+		limit = DefaultMaxParallelTools
+	}
+
+	results := make([]*Message, len(message.ToolCalls))
+	tools := make([]*Tool, len(message.ToolCalls))
+	for i, toolCall := range message.ToolCalls {
+		if toolCall.ID == "" {
+			toolCall.ID = fmt.Sprintf("call_%d_%d", len(c.Messages), i)
+		}
+		if err := ctx.Err(); err != nil {
+			results[i] = skippedToolMessage(toolCall, err)
+			continue
+		}
+		tool, registry, ok := c.findTool(toolCall.Name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Tool %s not found\n", toolCall.Name)
+			toolCall.Error = fmt.Sprintf("tool %s not found", toolCall.Name)
+			results[i] = newToolMessage(toolCall, nil)
+			continue
+		}
+		if err := c.authorize(ctx, tool, registry, toolCall); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			toolCall.Error = err.Error()
+			results[i] = newToolMessage(toolCall, err)
+			continue
+		}
+		tools[i] = tool
+	}
+
+	// The calls write their results to message while they run, so they are
+	// given a copy of the chat taken before any of them starts.
+	snapshot := c.snapshot()
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, toolCall := range message.ToolCalls {
+		if tools[i] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
 			}
-			tool, ok := c.ToolRegistry.Tools[toolName]
-			if !ok {
-				fmt.Printf("Tool %s not found\n", toolName)
-				continue
+			if err := ctx.Err(); err != nil {
+				results[i] = skippedToolMessage(toolCall, err)
+				return
 			}
-			toolCall["caller"] = c.Agent.Name
-			toolCall["prompt"] = c.Messages[len(c.Messages)-2].Content
+			results[i] = snapshot.runTool(ctx, tools[i], toolCall)
+		}()
+	}
+	wg.Wait()
 
-			results, err := tool.Call(toolCall, c)
-			if err != nil {
-				fmt.Printf("Error calling%s:%s\n", toolName, err)
-				continue
-			}
-			toolCall["result"] = results
+	c.Messages = append(c.Messages, results...)
+	return results
+}
+
+// runTool runs a single call and returns the tool message reporting it.
+func (c *Chat) runTool(ctx context.Context, tool *Tool, toolCall *ToolCall) *Message {
+	_, err := tool.CallContext(ctx, toolCall, c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calling %s: %s\n", toolCall.Name, err)
+	}
+	return newToolMessage(toolCall, err)
+}
+
+// skippedToolMessage reports a call that did not run because of err, the
+// error of the chat's context.
+func skippedToolMessage(toolCall *ToolCall, err error) *Message {
+	fmt.Fprintf(os.Stderr, "Skipping tool call %s: %s\n", toolCall.Name, err)
+	toolCall.Error = fmt.Sprintf("not run: %s", err)
+	return newToolMessage(toolCall, err)
+}
+
+// findTool looks the tool up in the chat's registry, then in the agent's or
+// in the tools that replace them for the call in progress. It also returns
+// the registry the tool was found in, whose policies apply to the call.
+func (c *Chat) findTool(name string) (*Tool, *ToolRegistry, bool) {
+	if c.ToolRegistry != nil {
+		if tool, ok := c.ToolRegistry.GetTool(name); ok {
+			return tool, c.ToolRegistry, true
 		}
 	}
+	registry := c.agentTools(c.tools)
+	tool, ok := registry.GetTool(name)
+	return tool, registry, ok
+}
+
+// lastUserPrompt returns the content of the most recent user message.
+func (c *Chat) lastUserPrompt() string {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			return c.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// newToolMessage builds the tool message reporting a call's result, or its error.
+// Invalid arguments are reported field by field so the model can correct the call.
+func newToolMessage(toolCall *ToolCall, err error) *Message {
+	content := map[string]interface{}{"result": toolCall.Result}
+	var argumentError *ArgumentError
+	if errors.As(err, &argumentError) {
+		content = argumentError.toolResult()
+	} else if toolCall.Error != "" {
+		content = map[string]interface{}{"error": toolCall.Error}
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		data = []byte(fmt.Sprintf(`{"error": %q}`, err.Error()))
+	}
+	message := NewMessage("tool", string(data))
+	message.ToolCallID = toolCall.ID
+	message.ToolName = toolCall.Name
+	message.ToolError = err != nil || toolCall.Error != ""
+	message.Time = time.Now()
+	return message
 }
 
 func NewProvider(baseurl, generate, chat string) *Provider {
@@ -358,6 +565,17 @@ type Chat struct {
 	Agent        *Agent        `json:"Agent"`
 	Messages     []*Message    `json:"messages"`
 	ToolRegistry *ToolRegistry `json:"omitempty"`
+	Options      *ModelOptions `json:"options,omitempty"` // overrides the agent model's options for this chat
+	// MaxParallelTools bounds how many tool calls RunTools runs at once; 0 means DefaultMaxParallelTools.
+	MaxParallelTools int `json:"maxParallelTools,omitempty"`
+	// Approver decides calls to tools with the ToolAsk policy; without one they are denied.
+	Approver Approver `json:"-"`
+	// Runtime is passed to the chat's provider calls and tool handlers; nil
+	// leaves it to the request context, then DefaultRuntime.
+	Runtime *Runtime `json:"-"`
+
+	ctx   context.Context
+	tools *ToolRegistry // replaces the agent's tools for the call in progress
 }
 
 func NewChat(agent *Agent, registry *ToolRegistry) *Chat {
@@ -368,6 +586,26 @@ func NewChat(agent *Agent, registry *ToolRegistry) *Chat {
 	}
 }
 
+// snapshot returns a copy of the chat with its own copy of the messages, for
+// tool handlers: they may run in parallel, or outlive a timeout, while the
+// chat goes on.
+func (c *Chat) snapshot() *Chat {
+	snapshot := *c
+	snapshot.Messages = make([]*Message, len(c.Messages))
+	for i, message := range c.Messages {
+		copied := *message
+		if message.ToolCalls != nil {
+			copied.ToolCalls = make([]*ToolCall, len(message.ToolCalls))
+			for j, toolCall := range message.ToolCalls {
+				callCopy := *toolCall
+				copied.ToolCalls[j] = &callCopy
+			}
+		}
+		snapshot.Messages[i] = &copied
+	}
+	return &snapshot
+}
+
 // clear clears the chat messages without resetting the system prompt.
 func (c *Chat) Clear() {
 	c.Messages = make([]*Message, 0)
@@ -422,6 +660,11 @@ func (c *Chat) SendUserMessage(content string, stream bool) (*ChatResponse, erro
 	return c.SendMessage("user", content, stream)
 }
 
+func (c *Chat) SendUserMessageContext(ctx context.Context, content string, stream bool) (*ChatResponse, error) {
+	content = "**User Prompt**:\n " + content
+	return c.SendMessageContext(ctx, "user", content, stream)
+}
+
 func (c *Chat) SendAssistantMessage(content string, stream bool) (*ChatResponse, error) {
 	content = "**Assistant Response**:\n " + content
 	return c.SendMessage("assistant", content, stream)
@@ -433,13 +676,24 @@ func (c *Chat) SendSystemMessage(content string, stream bool) (*ChatResponse, er
 }
 
 type Message struct {
-	Role      string                   `json:"role"`
-	Content   string                   `json:"content"`
-	Thinking  string                   `json:"thinking"`
-	Raw       string                   `json:"-"`
-	Images    []string                 `json:"images,omitempty"`
-	ToolCalls []map[string]interface{} `json:"tool_calls,omitempty"`
-	Time      time.Time                `json:"time"`
+	Role           string          `json:"role"`
+	Content        string          `json:"content"`
+	Thinking       string          `json:"thinking,omitempty"`
+	ThinkingBlocks []ThinkingBlock `json:"-"` // required by Anthropic to replay thinking
+	Raw            string          `json:"-"`
+	Images         []string        `json:"images,omitempty"`
+	ToolCalls      []*ToolCall     `json:"tool_calls,omitempty"`
+	ToolCallID     string          `json:"tool_call_id,omitempty"`
+	ToolName       string          `json:"tool_name,omitempty"`
+	ToolError      bool            `json:"-"` // the tool message reports a failed call
+	Time           time.Time       `json:"time"`
+}
+
+// ThinkingBlock is one block of a model's reasoning along with the signature
+// the provider issued for it.
+type ThinkingBlock struct {
+	Thinking  string
+	Signature string
 }
 
 func NewMessage(role, content string) *Message {
@@ -466,14 +720,12 @@ func (m *Message) BindToolResult(key string, v interface{}) error {
 	}
 
 	for _, toolCall := range m.ToolCalls {
-		toolCall = toolCall["function"].(map[string]interface{})
-		if name, ok := toolCall["name"].(string); ok && name == key {
-			rawResult, ok := toolCall["result"]
-			if !ok || rawResult == nil {
+		if toolCall.Name == key {
+			if toolCall.Result == nil {
 				return fmt.Errorf("tool call %s has no result", key)
 			}
 
-			jsonBytes, err := json.Marshal(rawResult)
+			jsonBytes, err := json.Marshal(toolCall.Result)
 			if err != nil {
 				return fmt.Errorf("failed to marshal tool result: %w", err)
 			}
@@ -519,6 +771,7 @@ type ChatResponse struct {
 	Response           string    `json:"response,omitempty"`
 	Message            Message   `json:"message,omitempty"`
 	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason,omitempty"`
 	TotalDuration      int64     `json:"total_duration"`
 	LoadDuration       int64     `json:"load_duration"`
 	PromptEvalCount    int       `json:"prompt_eval_count"`
@@ -530,6 +783,8 @@ type ChatResponse struct {
 type Tool struct {
 	Type     string       `json:"type"`
 	Function ToolFunction `json:"function,omitempty"`
+	Timeout  Duration     `json:"timeout,omitempty"` // limits a single call; 0 means no limit
+	Policy   ToolPolicy   `json:"policy,omitempty"`  // allow (default), ask or deny
 }
 
 // AddConstraints adds one or more constraints to the tool function.
@@ -565,34 +820,131 @@ func (t *Tool) Clone() *Tool {
 			Description:  t.Function.Description,
 			Examples:     make([]string, len(t.Function.Examples)),
 			Constraints:  make([]string, len(t.Function.Constraints)),
-			Parameters:   t.Function.Parameters,
+			Parameters:   t.Function.Parameters.Clone(),
 			FunctionCall: t.Function.FunctionCall,
 		},
+		Timeout: t.Timeout,
+		Policy:  t.Policy,
 	}
 	copy(toolCopy.Function.Examples, t.Function.Examples)
 	copy(toolCopy.Function.Constraints, t.Function.Constraints)
 	return toolCopy
 }
 
-func (t *Tool) getFunctionCall() (func(map[string]interface{}, *Chat) (map[string]interface{}, error), error) {
+func (t *Tool) getFunctionCall() (ToolHandler, error) {
 	if t.Function.FunctionCall == nil {
 		return nil, fmt.Errorf("tool %s has no function call defined", t.Function.Name)
 	}
 	return t.Function.FunctionCall, nil
 }
 
-func (t *Tool) Call(arguments map[string]interface{}, chat *Chat) (map[string]interface{}, error) {
+func (t *Tool) Call(call *ToolCall, chat *Chat) (map[string]interface{}, error) {
+	return t.CallContext(context.Background(), call, chat)
+}
+
+// CallContext runs the tool for call and records the result, error and duration on it.
+// The handler gets ctx in its invocation, bounded by the tool's Timeout. A
+// handler that panics or overruns the timeout is reported as an error.
+func (t *Tool) CallContext(ctx context.Context, call *ToolCall, chat *Chat) (map[string]interface{}, error) {
+	results, err := t.invoke(ctx, call, chat)
+	if err != nil {
+		call.Error = err.Error()
+		return nil, err
+	}
+	call.Result = results
+	return results, nil
+}
+
+func (t *Tool) invoke(ctx context.Context, call *ToolCall, chat *Chat) (map[string]interface{}, error) {
 	functionCall, err := t.getFunctionCall()
 	if err != nil {
 		return nil, err
 	}
-	results, err := functionCall(arguments, chat)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err = t.validateArguments(call); err != nil {
+		return nil, err
+	}
+
+	if chat != nil {
+		ctx = chat.withRuntime(ctx)
+	}
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(t.Timeout))
+		defer cancel()
+	}
+	invocation := &ToolInvocation{Context: ctx, Call: call}
+	if chat != nil {
+		invocation.Chat = chat.snapshot()
+		invocation.Prompt = chat.lastUserPrompt()
+		if chat.Agent != nil {
+			invocation.Caller = chat.Agent.Name
+		}
+	}
+
+	start := time.Now()
+	results, err := t.runHandler(ctx, functionCall, invocation)
+	call.Duration = time.Since(start)
 	if err != nil {
 		return nil, fmt.Errorf("error calling tool %s: %w", t.Function.Name, err)
 	}
 	return results, nil
 }
 
+// runHandler calls the handler, returning as soon as ctx is done when the tool
+// has a timeout. The handler is left to finish in the background then.
+func (t *Tool) runHandler(ctx context.Context, handler ToolHandler, invocation *ToolInvocation) (map[string]interface{}, error) {
+	if t.Timeout <= 0 {
+		return recoverHandler(handler, invocation)
+	}
+	type outcome struct {
+		results map[string]interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		results, err := recoverHandler(handler, invocation)
+		done <- outcome{results, err}
+	}()
+	select {
+	case result := <-done:
+		return result.results, result.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s: %w", time.Duration(t.Timeout), ctx.Err())
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// recoverHandler calls the handler, turning a panic into an error.
+func recoverHandler(handler ToolHandler, invocation *ToolInvocation) (results map[string]interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			fmt.Fprintf(os.Stderr, "Tool %s panicked: %v\n%s", invocation.Call.Name, recovered, debug.Stack())
+			results, err = nil, fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(invocation)
+}
+
+// validateArguments checks call's arguments against the tool's parameters and
+// replaces them with their coerced form. Tools without declared parameters accept anything.
+func (t *Tool) validateArguments(call *ToolCall) error {
+	parameters := t.Function.Parameters
+	if len(parameters.Properties) == 0 && len(parameters.Required) == 0 {
+		return nil
+	}
+	arguments, problems := parameters.ValidateArguments(call.Arguments)
+	if len(problems) > 0 {
+		return &ArgumentError{Tool: t.Function.Name, Problems: problems}
+	}
+	call.Arguments = arguments
+	return nil
+}
+
 func (t *Tool) AsPrompt(maxExamples int) string {
 	examples := ""
 	if maxExamples < 0 {
@@ -620,12 +972,12 @@ func (t *Tool) AsPrompt(maxExamples int) string {
 
 // ToolFunction defines the structure of a tool function.
 type ToolFunction struct {
-	Name         string                                                              `json:"name"`
-	Description  string                                                              `json:"description"`
-	Examples     []string                                                            `json:"examples"` //Todo change to map[string]string for more flexibility
-	Constraints  []string                                                            `json:"constraints"`
-	Parameters   ToolParameters                                                      `json:"parameters,omitempty"`
-	FunctionCall func(map[string]interface{}, *Chat) (map[string]interface{}, error) `json:"-"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	Examples     []string       `json:"examples"` //Todo change to map[string]string for more flexibility
+	Constraints  []string       `json:"constraints"`
+	Parameters   ToolParameters `json:"parameters,omitempty"`
+	FunctionCall ToolHandler    `json:"-"`
 }
 
 // ToolParameters defines the parameters required by a tool function.
@@ -633,17 +985,12 @@ type ToolParameters struct {
 	Type       string                            `json:"type"`
 	Properties map[string]*ToolParameterProperty `json:"properties,omitempty"`
 	Required   []string                          `json:"required"`
+	// AdditionalProperties is false to reject arguments not in Properties, or
+	// the schema they must match; nil accepts them as they are.
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
 }
 
-// ToolParameterProperty defines a single parameter for a tool function.
-type ToolParameterProperty struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description"`
-	Enum        []string `json:"enum,omitempty"`
-	Required    bool     `json:"required,omitempty"`
-}
-
-func NewTool(Type, name, description string, functionCall func(map[string]interface{}, *Chat) (map[string]interface{}, error)) *Tool {
+func NewTool(Type, name, description string, functionCall ToolHandler) *Tool {
 	return &Tool{
 		Type: Type,
 		Function: ToolFunction{
@@ -662,6 +1009,16 @@ func NewToolParameters(Type string) *ToolParameters {
 	}
 }
 
+// Schema returns the parameters as a complete JSON Schema object, filling in
+// the type and required list that hand-written tool files may leave out.
+func (toolParameters ToolParameters) Schema() ToolParameters {
+	if toolParameters.Type == "" {
+		toolParameters.Type = "object"
+	}
+	toolParameters.Required = toolParameters.requiredNames() // never nil, so it is always sent
+	return toolParameters
+}
+
 func (toolParameters *ToolParameters) AddProperty(propertyName, Type, description string, enum []string, required bool) {
 	if toolParameters.Properties == nil {
 		toolParameters.Properties = make(map[string]*ToolParameterProperty)
@@ -672,93 +1029,186 @@ func (toolParameters *ToolParameters) AddProperty(propertyName, Type, descriptio
 	}
 }
 
-func NewToolParameterProperty(Type, description string, enum []string, required bool) *ToolParameterProperty {
-	return &ToolParameterProperty{
-		Type:        Type,
-		Description: description,
-		Enum:        enum,
-		Required:    required,
+// SetProperty adds a property built with the New*Property helpers.
+func (toolParameters *ToolParameters) SetProperty(propertyName string, property *ToolParameterProperty, required bool) {
+	if toolParameters.Properties == nil {
+		toolParameters.Properties = make(map[string]*ToolParameterProperty)
+	}
+	property.Required = required
+	toolParameters.Properties[propertyName] = property
+	if required && !slices.Contains(toolParameters.Required, propertyName) {
+		toolParameters.Required = append(toolParameters.Required, propertyName)
 	}
 }
 
-// ToolRegistry manages registered tools.
+// ToolRegistry is a set of tools by name, safe for concurrent use. It is
+// copy-on-write: changes replace its maps instead of modifying them, so a map
+// returned by GetToolMap never changes under the code reading it.
 type ToolRegistry struct {
-	Tools map[string]*Tool
+	mu       sync.RWMutex
+	tools    map[string]*Tool
+	policies map[string]ToolPolicy // per-tool overrides of Tool.Policy
+}
+
+// toolRegistryJSON is the encoded form of a ToolRegistry.
+type toolRegistryJSON struct {
+	Tools    map[string]*Tool      `json:"Tools"`
+	Policies map[string]ToolPolicy `json:"policies,omitempty"`
 }
 
 // NewToolRegistry creates a new tool registry.
 func NewToolRegistry(tool ...*Tool) *ToolRegistry {
-	if len(tool) > 0 {
-		registry := &ToolRegistry{Tools: make(map[string]*Tool)}
-		for _, t := range tool {
-			registry.RegisterTool(t)
-		}
-		return registry
+	registry := &ToolRegistry{tools: make(map[string]*Tool, len(tool))}
+	for _, t := range tool {
+		registry.tools[t.Function.Name] = t
 	}
-	return &ToolRegistry{Tools: make(map[string]*Tool)}
+	return registry
 }
 
 // RegisterTool adds a single tool to the registry.
 func (tr *ToolRegistry) RegisterTool(tool *Tool) {
-	tr.Tools[tool.Function.Name] = tool
+	tr.RegisterTools(tool)
 }
 
 // RegisterTools adds one or more tools to the registry.
 func (tr *ToolRegistry) RegisterTools(tools ...*Tool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	updated := make(map[string]*Tool, len(tr.tools)+len(tools))
+	for name, tool := range tr.tools {
+		updated[name] = tool
+	}
 	for _, tool := range tools {
-		tr.Tools[tool.Function.Name] = tool
+		updated[tool.Function.Name] = tool
 	}
+	tr.tools = updated
+}
+
+// GetTool returns the tool registered under name.
+func (tr *ToolRegistry) GetTool(name string) (*Tool, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	tool, ok := tr.tools[name]
+	return tool, ok
+}
+
+// Len returns the number of registered tools.
+func (tr *ToolRegistry) Len() int {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	return len(tr.tools)
 }
 
 // GetTools returns the list of registered tools.
 func (tr *ToolRegistry) GetTools() []*Tool {
-	Tools := make([]*Tool, 0, len(tr.Tools))
-	for _, tool := range tr.Tools {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	Tools := make([]*Tool, 0, len(tr.tools))
+	for _, tool := range tr.tools {
 		Tools = append(Tools, tool)
 	}
 	return Tools
 }
 
+// Swap replaces the registry's tools and policies with those of registry and
+// returns a registry holding the previous ones.
 func (tr *ToolRegistry) Swap(registry *ToolRegistry) *ToolRegistry {
 	if registry == nil {
 		return tr
 	}
-	if tr.Tools == nil {
-		tr.Tools = make(map[string]*Tool)
+	registry.mu.RLock()
+	tools, policies := registry.tools, registry.policies
+	registry.mu.RUnlock()
+	if tools == nil {
+		tools = make(map[string]*Tool)
 	}
-	oldRegistry := tr.GetTools()
-	tr.Tools = registry.GetToolMap()
-	return NewToolRegistry(oldRegistry...)
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	previous := &ToolRegistry{tools: tr.tools, policies: tr.policies}
+	tr.tools, tr.policies = tools, policies
+	return previous
 }
 
-// GetToolMap returns the internal map of tools.
+// GetToolMap returns the registry's current map of tools. The registry never
+// modifies it afterwards, and neither may the caller.
 func (tr *ToolRegistry) GetToolMap() map[string]*Tool {
-	if tr.Tools == nil {
-		tr.Tools = make(map[string]*Tool)
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	if tr.tools == nil {
+		return map[string]*Tool{}
 	}
-	return tr.Tools
+	return tr.tools
 }
 
-// Clear removes all tools from the registry and returns a new ToolRegistry with the previously registered tools.
-// It does not delete the tools but clears the registry's internal map.
+// Clear removes all tools from the registry and returns a new ToolRegistry with the previously registered tools
+// and the policies. The policies stay in place for tools registered again.
 func (tr *ToolRegistry) Clear() *ToolRegistry {
-	tools := tr.GetTools()
-	tr.Tools = make(map[string]*Tool)
-	return NewToolRegistry(tools...)
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	previous := &ToolRegistry{tools: tr.tools, policies: tr.policies}
+	tr.tools = make(map[string]*Tool)
+	return previous
+}
+
+// Clone returns a deep copy of the registry: its tools and policies can be
+// changed without affecting the original.
+func (tr *ToolRegistry) Clone() *ToolRegistry {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	clone := &ToolRegistry{tools: make(map[string]*Tool, len(tr.tools))}
+	for name, tool := range tr.tools {
+		clone.tools[name] = tool.Clone()
+	}
+	if tr.policies != nil {
+		clone.policies = make(map[string]ToolPolicy, len(tr.policies))
+		for name, policy := range tr.policies {
+			clone.policies[name] = policy
+		}
+	}
+	return clone
 }
 
 // GetToolByName retrieves a tool by its name from the registry.
 // It returns an error if the tool is not found.
 func (tr *ToolRegistry) GetToolsByName(name ...string) *ToolRegistry {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
 	var registry = NewToolRegistry()
-	if tr.Tools == nil {
-		tr.Tools = make(map[string]*Tool)
-		return registry
-	}
 	for _, n := range name {
-		if tool, ok := tr.Tools[n]; ok {
-			registry.RegisterTool(tool)
+		if tool, ok := tr.tools[n]; ok {
+			registry.tools[n] = tool
+		}
+		if policy, ok := tr.policies[n]; ok {
+			if registry.policies == nil {
+				registry.policies = make(map[string]ToolPolicy)
+			}
+			registry.policies[n] = policy
 		}
 	}
 	return registry
 }
+
+func (tr *ToolRegistry) MarshalJSON() ([]byte, error) {
+	tr.mu.RLock()
+	encoded := toolRegistryJSON{Tools: tr.tools, Policies: tr.policies}
+	tr.mu.RUnlock()
+	if encoded.Tools == nil {
+		encoded.Tools = map[string]*Tool{}
+	}
+	return json.Marshal(encoded)
+}
+
+func (tr *ToolRegistry) UnmarshalJSON(data []byte) error {
+	var decoded toolRegistryJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Tools == nil {
+		decoded.Tools = make(map[string]*Tool)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.tools = decoded.Tools
+	tr.policies = decoded.Policies
+	return nil
+}
@@ -0,0 +1,54 @@
+package goAgent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunToolsAnswersEveryCall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := NewTool("function", "stop", "cancels the request", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		cancel()
+		return map[string]interface{}{}, nil
+	})
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		reply := callTool("stop", map[string]interface{}{})
+		calls := reply["tool_calls"].([]interface{})
+		reply["tool_calls"] = []interface{}{calls[0], calls[0], calls[0]}
+		return reply
+	})
+	chat := NewChat(fake.agent(stop), nil)
+	chat.MaxParallelTools = 1 // the first call cancels ctx before the others start
+
+	_, err := chat.Run(ctx, "user", "go", nil)
+	if err == nil {
+		t.Fatal("expected the cancellation error")
+	}
+	var reply *Message
+	for _, message := range chat.Messages {
+		if len(message.ToolCalls) > 0 {
+			reply = message
+		}
+	}
+	answered := map[string]*Message{}
+	for _, message := range chat.Messages {
+		if message.Role == "tool" {
+			answered[message.ToolCallID] = message
+		}
+	}
+	skipped := 0
+	for _, call := range reply.ToolCalls {
+		message, ok := answered[call.ID]
+		if !ok {
+			t.Fatalf("tool call %s has no tool message", call.ID)
+		}
+		if strings.Contains(message.Content, "context canceled") {
+			skipped++
+		}
+	}
+	if skipped != 2 {
+		t.Fatalf("%d calls report the cancellation, want 2", skipped)
+	}
+}
@@ -0,0 +1,444 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	BackendAnthropic = "anthropic"
+
+	anthropicMessagesEndpoint = "/v1/messages"
+	anthropicModelsEndpoint   = "/v1/models"
+	anthropicVersion          = "2023-06-01"
+
+	anthropicDefaultMaxTokens      = 4096
+	anthropicDefaultThinkingBudget = 2048
+	anthropicMinThinkingBudget     = 1024
+)
+
+// anthropicBackend speaks the Anthropic Messages API.
+type anthropicBackend struct {
+	provider *Provider
+}
+
+func newAnthropicBackend(provider *Provider) Backend {
+	return &anthropicBackend{provider: provider}
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is the union of every content block type we send or receive.
+type anthropicContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// image
+	Source *anthropicImageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema ToolParameters `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Role       string                  `json:"role"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (a *anthropicBackend) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	system, messages := toAnthropicMessages(req.Messages)
+	if req.Format != nil {
+		// The Messages API has no JSON mode, so the schema is given as an instruction.
+		system = strings.TrimSpace(system + "\n\n" + formatInstruction(req.Format))
+	}
+	payload := map[string]interface{}{
+		"model":    req.Model.Name,
+		"messages": messages,
+	}
+	req.Model.Options.applyAnthropic(payload, req.Model.Reasoning)
+	if system != "" {
+		payload["system"] = system
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = toAnthropicTools(req.Tools)
+	}
+
+	if req.Stream {
+		payload["stream"] = true
+		return a.sendStream(ctx, payload, req.OnEvent)
+	}
+	return a.send(ctx, payload)
+}
+
+func (a *anthropicBackend) Generate(ctx context.Context, req *GenerateRequest) (*ChatResponse, error) {
+	payload := map[string]interface{}{
+		"model": req.Model.Name,
+		"messages": []anthropicMessage{{
+			Role:    "user",
+			Content: []anthropicContentBlock{{Type: "text", Text: req.Prompt}},
+		}},
+	}
+	if req.System != "" {
+		payload["system"] = req.System
+	}
+	req.Model.Options.applyAnthropic(payload, false)
+
+	response, err := a.send(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	response.Response = response.Message.Content
+	return response, nil
+}
+
+// Embed is not offered by the Messages API.
+func (a *anthropicBackend) Embed(ctx context.Context, model Model, content string) ([]float64, error) {
+	return nil, ErrNotSupported
+}
+
+// Tokenize is not offered by the Messages API; it only exposes token counts.
+func (a *anthropicBackend) Tokenize(ctx context.Context, model Model, content string) ([]int, error) {
+	return nil, ErrNotSupported
+}
+
+func (a *anthropicBackend) ListModels(ctx context.Context) ([]*ModelInfo, error) {
+	body, err := getJSON(ctx, a.provider.endpointUrl(a.provider.ModelsEndpoint, anthropicModelsEndpoint), a.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []anthropicModel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding model list: %w", err)
+	}
+	models := make([]*ModelInfo, 0, len(result.Data))
+	for _, model := range result.Data {
+		models = append(models, model.info())
+	}
+	return models, nil
+}
+
+func (a *anthropicBackend) ShowModel(ctx context.Context, name string) (*ModelInfo, error) {
+	modelsUrl := a.provider.endpointUrl(a.provider.ModelsEndpoint, anthropicModelsEndpoint)
+	body, err := getJSON(ctx, modelsUrl+"/"+url.PathEscape(name), a.header())
+	if err != nil {
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrModelNotFound, name)
+		}
+		return nil, err
+	}
+	var model anthropicModel
+	if err := json.Unmarshal(body, &model); err != nil {
+		return nil, fmt.Errorf("error decoding model details: %w", err)
+	}
+	return model.info(), nil
+}
+
+// anthropicModel is an entry of /v1/models. The limits and capabilities are
+// only reported by newer API versions and are left unknown otherwise.
+type anthropicModel struct {
+	ID             string `json:"id"`
+	MaxInputTokens int    `json:"max_input_tokens"`
+	Capabilities   map[string]struct {
+		Supported bool `json:"supported"`
+	} `json:"capabilities"`
+}
+
+func (m anthropicModel) info() *ModelInfo {
+	info := &ModelInfo{Name: m.ID, ContextLength: m.MaxInputTokens}
+	if m.Capabilities == nil {
+		return info
+	}
+	info.Capabilities = []string{CapabilityCompletion, CapabilityTools}
+	if m.Capabilities["image_input"].Supported {
+		info.Capabilities = append(info.Capabilities, CapabilityVision)
+	}
+	if m.Capabilities["thinking"].Supported {
+		info.Capabilities = append(info.Capabilities, CapabilityThinking)
+	}
+	return info
+}
+
+func (a *anthropicBackend) send(ctx context.Context, payload map[string]interface{}) (*ChatResponse, error) {
+	body, err := postJSON(ctx, a.provider.endpointUrl(a.provider.ChatEndpoint, anthropicMessagesEndpoint), payload, a.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result anthropicResponse
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return fromAnthropicResponse(&result), nil
+}
+
+type anthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *anthropicResponse     `json:"message"`
+	ContentBlock *anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJson string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// sendStream reads the Messages API event stream, forwarding text and thinking
+// deltas to handler while rebuilding the content blocks of the final message.
+func (a *anthropicBackend) sendStream(ctx context.Context, payload map[string]interface{}, handler StreamHandler) (*ChatResponse, error) {
+	body, err := postStream(ctx, a.provider.endpointUrl(a.provider.ChatEndpoint, anthropicMessagesEndpoint), payload, a.header())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	result := &anthropicResponse{}
+	inputs := map[int]*strings.Builder{}
+	err = readSSE(body, func(_ string, data []byte) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return fmt.Errorf("anthropic stream error")
+		case "message_start":
+			if event.Message != nil {
+				result.ID = event.Message.ID
+				result.Model = event.Message.Model
+				result.Usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			for len(result.Content) <= event.Index {
+				result.Content = append(result.Content, anthropicContentBlock{})
+			}
+			if event.ContentBlock != nil {
+				result.Content[event.Index] = *event.ContentBlock
+				result.Content[event.Index].Input = nil
+			}
+		case "content_block_delta":
+			if event.Index >= len(result.Content) {
+				return nil
+			}
+			block := &result.Content[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				block.Text += event.Delta.Text
+				handler.emit(&StreamEvent{Type: StreamContent, Delta: event.Delta.Text})
+			case "thinking_delta":
+				block.Thinking += event.Delta.Thinking
+				handler.emit(&StreamEvent{Type: StreamThinking, Delta: event.Delta.Thinking})
+			case "signature_delta":
+				block.Signature += event.Delta.Signature
+			case "input_json_delta":
+				if inputs[event.Index] == nil {
+					inputs[event.Index] = &strings.Builder{}
+				}
+				inputs[event.Index].WriteString(event.Delta.PartialJson)
+			}
+		case "message_delta":
+			result.StopReason = event.Delta.StopReason
+			result.Usage.OutputTokens = event.Usage.OutputTokens
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for index, input := range inputs {
+		result.Content[index].Input = json.RawMessage(input.String())
+	}
+	return fromAnthropicResponse(result), nil
+}
+
+func (a *anthropicBackend) header() http.Header {
+	header := http.Header{}
+	header.Set("anthropic-version", anthropicVersion)
+	if a.provider.ApiKey != "" {
+		header.Set("x-api-key", a.provider.ApiKey)
+	}
+	return header
+}
+
+// toAnthropicMessages splits out the system prompt and converts the remaining
+// messages to content blocks. Tool results become user tool_result blocks and
+// consecutive messages with the same role are merged, as the API requires.
+func toAnthropicMessages(messages []*Message) (string, []anthropicMessage) {
+	var system []string
+	converted := make([]anthropicMessage, 0, len(messages))
+	for _, message := range messages {
+		role := message.Role
+		var blocks []anthropicContentBlock
+
+		switch role {
+		case "system":
+			if message.Content != "" {
+				system = append(system, message.Content)
+			}
+			continue
+		case "tool":
+			role = "user"
+			if message.ToolCallID != "" {
+				blocks = append(blocks, anthropicContentBlock{
+					Type:      "tool_result",
+					ToolUseID: message.ToolCallID,
+					Content:   message.Content,
+					IsError:   message.ToolError,
+				})
+			} else if message.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: "Tool result:\n" + message.Content})
+			}
+		case "assistant":
+			for _, thinking := range message.ThinkingBlocks {
+				if thinking.Signature == "" {
+					continue
+				}
+				blocks = append(blocks, anthropicContentBlock{
+					Type:      "thinking",
+					Thinking:  thinking.Thinking,
+					Signature: thinking.Signature,
+				})
+			}
+			if message.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: message.Content})
+			}
+			for i, toolCall := range message.ToolCalls {
+				id := toolCall.ID
+				if id == "" {
+					id = fmt.Sprintf("toolu_%d", i)
+				}
+				blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: id, Name: toolCall.Name, Input: toolCall.argumentObject()})
+			}
+		default:
+			role = "user"
+			for _, image := range message.Images {
+				blocks = append(blocks, anthropicContentBlock{Type: "image", Source: anthropicImage(image)})
+			}
+			if message.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: message.Content})
+			}
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == role {
+			converted[last].Content = append(converted[last].Content, blocks...)
+			continue
+		}
+		converted = append(converted, anthropicMessage{Role: role, Content: blocks})
+	}
+	return strings.Join(system, "\n\n"), converted
+}
+
+func toAnthropicTools(tools []*Tool) []anthropicTool {
+	converted := make([]anthropicTool, 0, len(tools))
+	for _, tool := range tools {
+		converted = append(converted, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters.Schema(),
+		})
+	}
+	return converted
+}
+
+func anthropicImage(image string) *anthropicImageSource {
+	mediaType := "image/png"
+	if decoded, err := base64.StdEncoding.DecodeString(image); err == nil {
+		mediaType = http.DetectContentType(decoded)
+	}
+	return &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: image}
+}
+
+func fromAnthropicResponse(result *anthropicResponse) *ChatResponse {
+	response := &ChatResponse{
+		Model:           result.Model,
+		CreatedAt:       time.Now(),
+		Done:            true,
+		DoneReason:      result.StopReason,
+		PromptEvalCount: result.Usage.InputTokens,
+		EvalCount:       result.Usage.OutputTokens,
+		Message:         Message{Role: "assistant"},
+	}
+
+	var text, thinking []string
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "thinking":
+			thinking = append(thinking, block.Thinking)
+			response.Message.ThinkingBlocks = append(response.Message.ThinkingBlocks, ThinkingBlock{
+				Thinking:  block.Thinking,
+				Signature: block.Signature,
+			})
+		case "tool_use":
+			response.Message.ToolCalls = append(response.Message.ToolCalls, NewToolCall(block.ID, block.Name, block.Input))
+		}
+	}
+	response.Message.Content = strings.Join(text, "")
+	response.Message.Raw = response.Message.Content
+	response.Message.Thinking = strings.Join(thinking, "\n")
+	return response
+}
@@ -0,0 +1,310 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAnthropic serves the Messages API, answering the n-th request with the
+// n-th reply. Replies to streaming requests are written as they are, so they
+// should hold the event stream. It records the decoded requests and headers.
+type fakeAnthropic struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	headers  []http.Header
+}
+
+func newFakeAnthropic(t *testing.T, replies ...string) *fakeAnthropic {
+	t.Helper()
+	fake := &fakeAnthropic{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != anthropicMessagesEndpoint {
+			http.NotFound(w, r)
+			return
+		}
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, request)
+		fake.headers = append(fake.headers, r.Header.Clone())
+		n := len(fake.requests)
+		fake.mu.Unlock()
+		if n > len(replies) {
+			http.Error(w, "no reply left", http.StatusInternalServerError)
+			return
+		}
+		if request["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		fmt.Fprint(w, replies[n-1])
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeAnthropic) provider() *Provider {
+	return &Provider{Type: BackendAnthropic, BaseUrl: f.URL, ApiKey: "test-key"}
+}
+
+// blocks returns the content blocks of the i-th message of a recorded request.
+func blocks(t *testing.T, request map[string]interface{}, i int) []map[string]interface{} {
+	t.Helper()
+	messages := request["messages"].([]interface{})
+	if i >= len(messages) {
+		t.Fatalf("request has %d messages, want more than %d", len(messages), i)
+	}
+	var result []map[string]interface{}
+	for _, block := range messages[i].(map[string]interface{})["content"].([]interface{}) {
+		result = append(result, block.(map[string]interface{}))
+	}
+	return result
+}
+
+const anthropicTextReply = `{"id":"msg_1","model":"claude-test","role":"assistant",
+	"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn",
+	"usage":{"input_tokens":10,"output_tokens":2}}`
+
+func TestAnthropicRequestShape(t *testing.T) {
+	fake := newFakeAnthropic(t, anthropicTextReply)
+	assistant := NewMessage("assistant", "")
+	assistant.Thinking = "first\nsecond"
+	assistant.ThinkingBlocks = []ThinkingBlock{
+		{Thinking: "first", Signature: "sig-1"},
+		{Thinking: "second", Signature: "sig-2"},
+	}
+	assistant.ToolCalls = []*ToolCall{NewToolCall("toolu_1", "lookup", []byte(`{"q":"go"}`))}
+	result := NewMessage("tool", `{"error":"boom"}`)
+	result.ToolCallID = "toolu_1"
+	result.ToolError = true
+	lookup := NewTool("function", "lookup", "looks things up", nil)
+
+	response, err := newAnthropicBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "claude-test"},
+		Messages: []*Message{NewMessage("system", "be brief"), NewMessage("user", "find go"), assistant, result},
+		Tools:    []*Tool{lookup},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Message.Content != "ok" || response.PromptEvalCount != 10 || response.EvalCount != 2 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	header := fake.headers[0]
+	if header.Get("x-api-key") != "test-key" || header.Get("anthropic-version") != anthropicVersion {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	request := fake.requests[0]
+	if request["model"] != "claude-test" || request["system"] != "be brief" {
+		t.Fatalf("unexpected model or system in %v", request)
+	}
+	if request["max_tokens"] != float64(anthropicDefaultMaxTokens) {
+		t.Fatalf("max_tokens = %v", request["max_tokens"])
+	}
+	if messages := request["messages"].([]interface{}); len(messages) != 3 {
+		t.Fatalf("got %d messages, want user, assistant and tool result", len(messages))
+	}
+
+	sent := blocks(t, request, 1)
+	if len(sent) != 3 {
+		t.Fatalf("assistant message has %d blocks, want 2 thinking and 1 tool_use", len(sent))
+	}
+	for i, want := range []string{"sig-1", "sig-2"} {
+		if sent[i]["type"] != "thinking" || sent[i]["signature"] != want {
+			t.Fatalf("block %d = %v, want a thinking block signed %s", i, sent[i], want)
+		}
+	}
+	if sent[0]["thinking"] != "first" || sent[1]["thinking"] != "second" {
+		t.Fatalf("thinking blocks were not replayed one by one: %v", sent)
+	}
+	if sent[2]["type"] != "tool_use" || sent[2]["id"] != "toolu_1" || sent[2]["input"].(map[string]interface{})["q"] != "go" {
+		t.Fatalf("unexpected tool_use block %v", sent[2])
+	}
+
+	toolResult := blocks(t, request, 2)[0]
+	if toolResult["type"] != "tool_result" || toolResult["tool_use_id"] != "toolu_1" || toolResult["is_error"] != true {
+		t.Fatalf("unexpected tool_result block %v", toolResult)
+	}
+
+	tools := request["tools"].([]interface{})
+	tool := tools[0].(map[string]interface{})
+	if tool["name"] != "lookup" || tool["input_schema"].(map[string]interface{})["type"] != "object" {
+		t.Fatalf("unexpected tool %v", tool)
+	}
+}
+
+func TestAnthropicToolResultWithoutError(t *testing.T) {
+	_, messages := toAnthropicMessages([]*Message{{Role: "tool", ToolCallID: "toolu_1", Content: `{"result":{}}`}})
+	if block := messages[0].Content[0]; block.IsError {
+		t.Fatalf("successful tool result marked as an error: %+v", block)
+	}
+	data, err := json.Marshal(messages[0].Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "is_error") {
+		t.Fatalf("is_error sent for a successful call: %s", data)
+	}
+}
+
+// sse writes events as a Messages API event stream.
+func sse(events ...string) string {
+	var stream strings.Builder
+	for _, event := range events {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal([]byte(event), &typed)
+		fmt.Fprintf(&stream, "event: %s\ndata: %s\n\n", typed.Type, event)
+	}
+	return stream.String()
+}
+
+func TestAnthropicStream(t *testing.T) {
+	fake := newFakeAnthropic(t, sse(
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-test","role":"assistant","content":[],"usage":{"input_tokens":12}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"look "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"it up"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig-1"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"thinking_delta","thinking":"then answer"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"signature_delta","signature":"sig-2"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":" there"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"content_block_start","index":3,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+		`{"type":"content_block_delta","index":3,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+		`{"type":"content_block_delta","index":3,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}`,
+		`{"type":"content_block_stop","index":3}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+		`{"type":"message_stop"}`,
+	))
+	var content, thinking strings.Builder
+	response, err := newAnthropicBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "claude-test"},
+		Messages: []*Message{NewMessage("user", "hi")},
+		Stream:   true,
+		OnEvent: func(event *StreamEvent) {
+			switch event.Type {
+			case StreamContent:
+				content.WriteString(event.Delta)
+			case StreamThinking:
+				thinking.WriteString(event.Delta)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.requests[0]["stream"] != true {
+		t.Fatal("stream was not requested")
+	}
+
+	if content.String() != "Hello there" || thinking.String() != "look it upthen answer" {
+		t.Fatalf("streamed content %q and thinking %q", content.String(), thinking.String())
+	}
+	message := response.Message
+	if message.Content != "Hello there" || message.Thinking != "look it up\nthen answer" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+	want := []ThinkingBlock{{Thinking: "look it up", Signature: "sig-1"}, {Thinking: "then answer", Signature: "sig-2"}}
+	if len(message.ThinkingBlocks) != len(want) {
+		t.Fatalf("got thinking blocks %+v, want %+v", message.ThinkingBlocks, want)
+	}
+	for i := range want {
+		if message.ThinkingBlocks[i] != want[i] {
+			t.Fatalf("got thinking blocks %+v, want %+v", message.ThinkingBlocks, want)
+		}
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].ID != "toolu_1" || string(message.ToolCalls[0].Arguments) != `{"q":"go"}` {
+		t.Fatalf("unexpected tool calls %+v", message.ToolCalls)
+	}
+	if response.DoneReason != "tool_use" || response.PromptEvalCount != 12 || response.EvalCount != 30 {
+		t.Fatalf("unexpected stop reason or usage %+v", response)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	fake := newFakeAnthropic(t, sse(
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-test","role":"assistant","content":[]}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	))
+	_, err := newAnthropicBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "claude-test"},
+		Messages: []*Message{NewMessage("user", "hi")},
+		Stream:   true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Fatalf("got %v, want the stream error", err)
+	}
+}
+
+func TestAnthropicToolUse(t *testing.T) {
+	fake := newFakeAnthropic(t,
+		`{"id":"msg_1","model":"claude-test","role":"assistant","content":[
+			{"type":"thinking","thinking":"need data","signature":"sig-1"},
+			{"type":"thinking","thinking":"call both","signature":"sig-2"},
+			{"type":"tool_use","id":"toolu_ok","name":"lookup","input":{"q":"go"}},
+			{"type":"tool_use","id":"toolu_fail","name":"broken","input":{}}
+		],"stop_reason":"tool_use","usage":{"input_tokens":5,"output_tokens":5}}`,
+		anthropicTextReply,
+	)
+	lookup := NewTool("function", "lookup", "looks things up", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		return map[string]interface{}{"answer": "a language"}, nil
+	})
+	broken := NewTool("function", "broken", "always fails", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	agent := &Agent{
+		Name:     "Claude",
+		Model:    Model{Name: "claude-test", ContextWindow: 4096},
+		Provider: fake.provider(),
+		Tools:    NewToolRegistry(lookup, broken),
+	}
+
+	result, err := NewChat(agent, nil).Run(context.Background(), "user", "what is go?", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Response.Message.Content != "ok" || len(result.Steps) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(fake.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(fake.requests))
+	}
+
+	request := fake.requests[1]
+	sent := blocks(t, request, 1)
+	if sent[0]["signature"] != "sig-1" || sent[1]["signature"] != "sig-2" {
+		t.Fatalf("thinking signatures were not replayed: %v", sent)
+	}
+	results := map[string]map[string]interface{}{}
+	for _, block := range blocks(t, request, 2) {
+		if block["type"] == "tool_result" {
+			results[block["tool_use_id"].(string)] = block
+		}
+	}
+	if ok := results["toolu_ok"]; ok == nil || ok["is_error"] != nil || !strings.Contains(ok["content"].(string), "a language") {
+		t.Fatalf("unexpected result for the successful call: %v", ok)
+	}
+	if failed := results["toolu_fail"]; failed == nil || failed["is_error"] != true || !strings.Contains(failed["content"].(string), "boom") {
+		t.Fatalf("unexpected result for the failed call: %v", failed)
+	}
+}
@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/EdersenC/goAgent"
+)
+
+// transport carries JSON-RPC messages to an MCP server.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, request *message) (*message, error)
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, notification *message) error
+	Close() error
+}
+
+// Client is a connection to an MCP server.
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+
+	ServerInfo      Implementation
+	ProtocolVersion string
+	Instructions    string
+}
+
+// ClientInfo identifies goAgent to the servers it connects to.
+var ClientInfo = Implementation{Name: "goAgent", Version: "0.1.0"}
+
+// ConnectStdio starts command as an MCP server speaking over its stdin and
+// stdout and performs the initialize handshake. The server's stderr is passed
+// through. Close stops the server.
+func ConnectStdio(ctx context.Context, command string, args ...string) (*Client, error) {
+	transport, err := startStdio(command, args...)
+	if err != nil {
+		return nil, err
+	}
+	return connect(ctx, transport)
+}
+
+// ConnectHTTP connects to an MCP server over the streamable HTTP transport at
+// url and performs the initialize handshake. header is sent with every
+// request, e.g. for authorization; it may be nil.
+func ConnectHTTP(ctx context.Context, url string, header http.Header) (*Client, error) {
+	return connect(ctx, newHTTPTransport(url, header))
+}
+
+func connect(ctx context.Context, transport transport) (*Client, error) {
+	client := &Client{transport: transport}
+	if err := client.initialize(ctx); err != nil {
+		_ = transport.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var result initializeResult
+	err := c.call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      ClientInfo,
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("mcp initialize failed: %w", err)
+	}
+	c.ServerInfo = result.ServerInfo
+	c.ProtocolVersion = result.ProtocolVersion
+	c.Instructions = result.Instructions
+	if httpTransport, ok := c.transport.(*httpTransport); ok {
+		httpTransport.protocolVersion = result.ProtocolVersion
+	}
+	return c.transport.notify(ctx, &message{JSONRPC: jsonRPCVersion, Method: "notifications/initialized"})
+}
+
+// call sends method with params and decodes the response's result into result.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	request := &message{
+		JSONRPC: jsonRPCVersion,
+		ID:      json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10)),
+		Method:  method,
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		request.Params = data
+	}
+
+	response, err := c.transport.roundTrip(ctx, request)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err = json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var result listToolsResult
+		if err := c.call(ctx, "tools/list", listToolsParams{Cursor: cursor}, &result); err != nil {
+			return nil, fmt.Errorf("mcp tools/list failed: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" || result.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool runs the named tool on the server. A result with IsError set is
+// returned as is; err is only set when the call itself failed.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, fmt.Errorf("mcp tools/call %s failed: %w", name, err)
+	}
+	return &result, nil
+}
+
+// Tools lists the server's tools as goAgent tools whose handlers proxy tools/call.
+func (c *Client) Tools(ctx context.Context) ([]*goAgent.Tool, error) {
+	serverTools, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]*goAgent.Tool, 0, len(serverTools))
+	for _, serverTool := range serverTools {
+		tool, err := c.toTool(serverTool)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// RegisterTools adds the server's tools to registry, so any agent using it can
+// call them, and returns them.
+func (c *Client) RegisterTools(ctx context.Context, registry *goAgent.ToolRegistry) ([]*goAgent.Tool, error) {
+	tools, err := c.Tools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registry.RegisterTools(tools...)
+	return tools, nil
+}
+
+func (c *Client) toTool(serverTool Tool) (*goAgent.Tool, error) {
+	name := serverTool.Name
+	tool := goAgent.NewTool("function", name, serverTool.Description, func(inv *goAgent.ToolInvocation) (map[string]interface{}, error) {
+		result, err := c.CallTool(inv.Context, name, inv.Call.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsError {
+			return nil, fmt.Errorf("%s", result.Text())
+		}
+		return resultMap(result), nil
+	})
+	if len(serverTool.InputSchema) > 0 {
+		if err := json.Unmarshal(serverTool.InputSchema, &tool.Function.Parameters); err != nil {
+			return nil, fmt.Errorf("invalid input schema for mcp tool %s: %w", name, err)
+		}
+	}
+	return tool, nil
+}
+
+// resultMap converts a tools/call result to the map sent back to the model:
+// the structured content when there is one, the text content otherwise.
+func resultMap(result *CallToolResult) map[string]interface{} {
+	if result.StructuredContent != nil {
+		return result.StructuredContent
+	}
+	output := map[string]interface{}{"content": result.Text()}
+	var other []Content
+	for _, content := range result.Content {
+		if content.Type != "text" {
+			other = append(other, Content{Type: content.Type, MimeType: content.MimeType})
+		}
+	}
+	if len(other) > 0 {
+		output["attachments"] = other // binary data is not forwarded to the model
+	}
+	return output
+}
+
+// Close ends the session and, for stdio servers, stops the process.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
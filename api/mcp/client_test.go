@@ -0,0 +1,288 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EdersenC/goAgent"
+)
+
+// fakeServer is the path of the built testdata/fakeserver command.
+var fakeServer string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mcp-fakeserver")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeServer = filepath.Join(dir, "fakeserver")
+	build := exec.Command("go", "build", "-o", fakeServer, "./testdata/fakeserver")
+	build.Stderr = os.Stderr
+	if err = build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to build the fake mcp server:", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func connectFake(t *testing.T, args ...string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := ConnectStdio(ctx, fakeServer, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// callTool runs the named tool of tools as an agent would.
+func callTool(ctx context.Context, tools []*goAgent.Tool, name, arguments string) (map[string]interface{}, error) {
+	for _, tool := range tools {
+		if tool.Function.Name == name {
+			return tool.CallContext(ctx, goAgent.NewToolCall("", name, []byte(arguments)), nil)
+		}
+	}
+	return nil, fmt.Errorf("no tool %s", name)
+}
+
+func TestStdioClient(t *testing.T) {
+	client := connectFake(t)
+	defer client.Close()
+	if client.ServerInfo.Name != "fake" || client.ProtocolVersion != ProtocolVersion || client.Instructions != "be nice" {
+		t.Fatalf("unexpected handshake: %+v", client)
+	}
+
+	ctx := context.Background()
+	tools, err := client.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	if strings.Join(names, ",") != "echo,fail,slow" {
+		t.Fatalf("got tools %v, want both pages", names)
+	}
+	if required := tools[0].Function.Parameters.Required; len(required) != 1 || required[0] != "text" {
+		t.Fatalf("input schema was not imported: %+v", tools[0].Function.Parameters)
+	}
+
+	result, err := callTool(ctx, tools, "echo", `{"text":"hello"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["text"] != "hello" || result["pinged"] != true {
+		t.Fatalf("got %v, want the echoed text and the ping answered", result)
+	}
+	if _, err = callTool(ctx, tools, "echo", `{}`); err == nil {
+		t.Fatal("arguments were not validated against the imported schema")
+	}
+	if _, err = callTool(ctx, tools, "fail", `{}`); err == nil || !strings.Contains(err.Error(), "it broke") {
+		t.Fatalf("got %v, want the tool's error", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err = callTool(timeout, tools, "slow", `{}`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context's error", err)
+	}
+	if _, err = callTool(ctx, tools, "echo", `{"text":"still there"}`); err != nil {
+		t.Fatalf("client unusable after a cancelled call: %v", err)
+	}
+}
+
+func TestStdioClose(t *testing.T) {
+	client := connectFake(t)
+	transport := client.transport.(*stdioTransport)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-transport.done:
+	default:
+		t.Fatal("Close returned before the server's output was read")
+	}
+	if !errors.Is(transport.err, io.EOF) {
+		t.Fatalf("reading stopped with %v, want the end of the server's output", transport.err)
+	}
+	if transport.cmd.ProcessState == nil || !transport.cmd.ProcessState.Success() {
+		t.Fatalf("server did not exit cleanly: %v", transport.cmd.ProcessState)
+	}
+	if _, err := client.ListTools(context.Background()); err == nil {
+		t.Fatal("ListTools succeeded on a closed client")
+	}
+}
+
+func TestStdioCloseKillsLingeringServer(t *testing.T) {
+	client := connectFake(t, "-linger")
+	transport := client.transport.(*stdioTransport)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if transport.cmd.ProcessState == nil || transport.cmd.ProcessState.Success() {
+		t.Fatalf("lingering server was not killed: %v", transport.cmd.ProcessState)
+	}
+}
+
+func TestStdioServerExit(t *testing.T) {
+	client := connectFake(t)
+	defer client.Close()
+	err := client.call(context.Background(), "exit", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "closed the connection") {
+		t.Fatalf("got %v, want the connection error", err)
+	}
+	if _, err = client.ListTools(context.Background()); err == nil {
+		t.Fatal("ListTools succeeded after the server exited")
+	}
+}
+
+// fakeHTTPServer serves the streamable HTTP transport. tools/call responses
+// are sent as event streams preceded by a notification, everything else as
+// JSON. It records the headers of every request.
+type fakeHTTPServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers []http.Header
+	deleted string
+}
+
+func newFakeHTTPServer(t *testing.T) *fakeHTTPServer {
+	t.Helper()
+	fake := &fakeHTTPServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeHTTPServer) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.headers = append(f.headers, r.Header.Clone())
+	f.mu.Unlock()
+	if r.Method == http.MethodDelete {
+		f.mu.Lock()
+		f.deleted = r.Header.Get(sessionHeader)
+		f.mu.Unlock()
+		return
+	}
+
+	var request message
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Method != "initialize" && r.Header.Get(sessionHeader) != "session-1" {
+		http.Error(w, "missing session", http.StatusBadRequest)
+		return
+	}
+	if request.isNotification() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	response := &message{JSONRPC: jsonRPCVersion, ID: request.ID}
+	switch request.Method {
+	case "initialize":
+		w.Header().Set(sessionHeader, "session-1")
+		response.Result, _ = json.Marshal(initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			ServerInfo:      Implementation{Name: "fake-http", Version: "1.0"},
+		})
+	case "tools/list":
+		response.Result, _ = json.Marshal(listToolsResult{Tools: []Tool{{
+			Name:        "echo",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		}}})
+	case "tools/call":
+		var params struct {
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		response.Result, _ = json.Marshal(CallToolResult{Content: []Content{{Type: "text", Text: params.Arguments.Text}}})
+		data, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		return
+	default:
+		response.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func TestHTTPClient(t *testing.T) {
+	fake := newFakeHTTPServer(t)
+	ctx := context.Background()
+	client, err := ConnectHTTP(ctx, fake.URL, http.Header{"Authorization": {"Bearer token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.ServerInfo.Name != "fake-http" {
+		t.Fatalf("unexpected server info %+v", client.ServerInfo)
+	}
+
+	registry := goAgent.NewToolRegistry()
+	if _, err = client.RegisterTools(ctx, registry); err != nil {
+		t.Fatal(err)
+	}
+	echo, ok := registry.GetTool("echo")
+	if !ok {
+		t.Fatal("echo was not registered")
+	}
+	result, err := echo.CallContext(ctx, goAgent.NewToolCall("", "echo", []byte(`{"text":"hello"}`)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["content"] != "hello" {
+		t.Fatalf("got %v, want the echoed text from the event stream", result)
+	}
+
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.deleted != "session-1" {
+		t.Fatalf("session %q was deleted, want session-1", fake.deleted)
+	}
+	for i, header := range fake.headers {
+		if header.Get("Authorization") != "Bearer token" {
+			t.Fatalf("request %d lacks the configured header", i)
+		}
+		if i > 0 && header.Get(protocolHeader) != ProtocolVersion {
+			t.Fatalf("request %d lacks the negotiated protocol version", i)
+		}
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	fake := newFakeHTTPServer(t)
+	client, err := ConnectHTTP(context.Background(), fake.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var rpcError *RPCError
+	if err = client.call(context.Background(), "resources/list", nil, nil); !errors.As(err, &rpcError) || rpcError.Code != CodeMethodNotFound {
+		t.Fatalf("got %v, want method not found", err)
+	}
+}
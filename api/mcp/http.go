@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	protocolHeader = "Mcp-Protocol-Version"
+)
+
+// httpTransport speaks the streamable HTTP transport: every message is a
+// POST, answered with either a JSON body or a server-sent event stream.
+type httpTransport struct {
+	url             string
+	header          http.Header
+	client          *http.Client
+	protocolVersion string // sent once negotiated
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newHTTPTransport(url string, header http.Header) *httpTransport {
+	return &httpTransport{url: url, header: header.Clone(), client: &http.Client{}}
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if t.protocolVersion != "" {
+		req.Header.Set(protocolHeader, t.protocolVersion)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) post(ctx context.Context, outgoing *message) (*http.Response, error) {
+	data, err := json.Marshal(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, data)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", outgoing.Method, err)
+	}
+	if sessionID := resp.Header.Get(sessionHeader); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("mcp server returned %s for %s: %s", resp.Status, outgoing.Method, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, request *message) (*message, error) {
+	resp, err := t.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var response message
+		if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, fmt.Errorf("failed to decode %s response: %w", request.Method, err)
+		}
+		return &response, nil
+	}
+	return readEventStream(resp.Body, string(request.ID))
+}
+
+// readEventStream reads server-sent events until the response with id arrives.
+// Notifications sent on the stream before it are skipped.
+func readEventStream(body io.Reader, id string) (*message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue // other fields, or a blank line without data
+		}
+		var incoming message
+		err := json.Unmarshal([]byte(data.String()), &incoming)
+		data.Reset()
+		if err == nil && !incoming.isRequest() && !incoming.isNotification() && string(incoming.ID) == id {
+			return &incoming, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mcp event stream: %w", err)
+	}
+	return nil, fmt.Errorf("mcp event stream ended without a response")
+}
+
+func (t *httpTransport) notify(ctx context.Context, notification *message) error {
+	resp, err := t.post(ctx, notification)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// Close ends the session on the server, if it issued one.
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,295 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/EdersenC/goAgent"
+)
+
+// Server publishes the tools of a ToolRegistry to MCP hosts.
+type Server struct {
+	Registry     *goAgent.ToolRegistry
+	Info         Implementation
+	Instructions string
+	// NewChat returns the chat a call runs in, for tools that use the calling
+	// agent; it may be nil, in which case tools get a chat without an agent.
+	NewChat func() *goAgent.Chat
+	// Approver decides every call to a tool with the ToolAsk policy. Without
+	// one those tools are not served, as a host cannot tell they need approval.
+	Approver goAgent.Approver
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+// NewServer serves the tools in registry.
+func NewServer(registry *goAgent.ToolRegistry, info Implementation) *Server {
+	return &Server{Registry: registry, Info: info}
+}
+
+// ServeStdio answers newline-delimited JSON-RPC messages read from in on out
+// until in is closed or ctx is cancelled. Tool calls run concurrently. Nothing
+// else may write to out while serving.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	s.running = make(map[string]context.CancelFunc)
+	s.mu.Unlock()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			var incoming message
+			if err := json.Unmarshal(line, &incoming); err != nil {
+				s.write(out, &message{JSONRPC: jsonRPCVersion, ID: json.RawMessage("null"),
+					Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+				continue
+			}
+			if incoming.isNotification() {
+				s.handleNotification(&incoming)
+				continue
+			}
+			if !incoming.isRequest() {
+				continue // responses to requests we never send
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.write(out, s.handle(ctx, &incoming))
+			}()
+		}
+	}
+}
+
+func (s *Server) write(out io.Writer, outgoing *message) {
+	data, err := json.Marshal(outgoing)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to encode mcp response:", err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err = out.Write(append(data, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write mcp response:", err)
+	}
+}
+
+func (s *Server) handleNotification(notification *message) {
+	if notification.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(notification.Params, &params); err != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel, ok := s.running[string(params.RequestID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// handle answers a single request.
+func (s *Server) handle(ctx context.Context, request *message) *message {
+	response := &message{JSONRPC: jsonRPCVersion, ID: request.ID}
+	var result interface{}
+	var err error
+	switch request.Method {
+	case "initialize":
+		result, err = s.initialize(request.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = listToolsResult{Tools: s.listTools()}
+	case "tools/call":
+		ctx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.running[string(request.ID)] = cancel
+		s.mu.Unlock()
+		result, err = s.callTool(ctx, request.Params)
+		s.mu.Lock()
+		delete(s.running, string(request.ID))
+		s.mu.Unlock()
+		cancel()
+	default:
+		err = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+	}
+
+	if err != nil {
+		var rpcError *RPCError
+		if !errors.As(err, &rpcError) {
+			rpcError = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		}
+		response.Error = rpcError
+		return response
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		response.Error = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		return response
+	}
+	response.Result = data
+	return response
+}
+
+func (s *Server) initialize(params json.RawMessage) (*initializeResult, error) {
+	var request initializeParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return &initializeResult{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+		ServerInfo:      s.Info,
+		Instructions:    s.Instructions,
+	}, nil
+}
+
+// listTools describes every tool the server serves, sorted by name.
+func (s *Server) listTools() []Tool {
+	tools := make([]Tool, 0)
+	for _, tool := range s.Registry.GetTools() {
+		if !s.serves(tool) {
+			continue
+		}
+		schema, err := json.Marshal(tool.Function.Parameters.Schema())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping tool %s: %s\n", tool.Function.Name, err)
+			continue
+		}
+		tools = append(tools, Tool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// policy returns the registry's override for tool, or the tool's own policy.
+func (s *Server) policy(tool *goAgent.Tool) goAgent.ToolPolicy {
+	if policy, ok := s.Registry.Policy(tool.Function.Name); ok && policy != "" {
+		return policy
+	}
+	if tool.Policy != "" {
+		return tool.Policy
+	}
+	return goAgent.ToolAllow
+}
+
+// serves reports whether tool is offered to hosts: allowed tools are, tools
+// that ask only when the server has an Approver, and denied ones never.
+func (s *Server) serves(tool *goAgent.Tool) bool {
+	switch s.policy(tool) {
+	case goAgent.ToolAllow:
+		return true
+	case goAgent.ToolAsk:
+		return s.Approver != nil
+	default:
+		return false
+	}
+}
+
+// authorize puts a call to a tool that asks to the server's Approver, which
+// may rewrite its arguments. A refused call is reported as a ToolDeniedError.
+func (s *Server) authorize(ctx context.Context, tool *goAgent.Tool, call *goAgent.ToolCall) error {
+	if s.policy(tool) != goAgent.ToolAsk {
+		return nil
+	}
+	approval, err := s.Approver.Approve(ctx, tool, call)
+	if err != nil {
+		return &goAgent.ToolDeniedError{Tool: call.Name, Reason: err.Error()}
+	}
+	if !approval.Approved {
+		reason := approval.Reason
+		if reason == "" {
+			reason = "the user declined it"
+		}
+		return &goAgent.ToolDeniedError{Tool: call.Name, Reason: reason}
+	}
+	if approval.Arguments != nil {
+		call.Arguments = goAgent.NewToolCall(call.ID, call.Name, approval.Arguments).Arguments
+	}
+	return nil
+}
+
+// callTool runs the tool through Tool.CallContext once authorize lets it.
+// Tool failures, including invalid arguments and denied calls, are reported
+// in the result with isError so the host's model can see them; tools that are
+// unknown or not served are a protocol error.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (*CallToolResult, error) {
+	var request callToolParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	tool, ok := s.Registry.GetTool(request.Name)
+	if !ok || !s.serves(tool) {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool: " + request.Name}
+	}
+
+	var chat *goAgent.Chat
+	if s.NewChat != nil {
+		chat = s.NewChat()
+	}
+	call := goAgent.NewToolCall("", request.Name, request.Arguments)
+	var results map[string]interface{}
+	err := s.authorize(ctx, tool, call)
+	if err == nil {
+		results, err = tool.CallContext(ctx, call, chat)
+	}
+	if err != nil {
+		var argumentError *goAgent.ArgumentError
+		text := err.Error()
+		if errors.As(err, &argumentError) {
+			if data, marshalErr := json.Marshal(argumentError.Problems); marshalErr == nil {
+				text = fmt.Sprintf("%s\ninvalid_arguments: %s", text, data)
+			}
+		}
+		return &CallToolResult{Content: []Content{{Type: "text", Text: text}}, IsError: true}, nil
+	}
+
+	text, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result of %s: %w", request.Name, err)
+	}
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: string(text)}},
+		StructuredContent: results,
+	}, nil
+}
@@ -0,0 +1,223 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EdersenC/goAgent"
+)
+
+// session runs a Server over in-memory pipes and sends it requests one at a time.
+type session struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Scanner
+	nextID int
+}
+
+func serve(t *testing.T, server *Server) *session {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_ = server.ServeStdio(ctx, inReader, outWriter)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = inWriter.Close()
+		_ = outReader.Close()
+		<-stopped
+	})
+	return &session{t: t, in: inWriter, out: bufio.NewScanner(outReader)}
+}
+
+// request sends method with params and returns the server's response.
+func (s *session) request(method string, params interface{}) *message {
+	s.t.Helper()
+	s.nextID++
+	request := &message{JSONRPC: jsonRPCVersion, ID: json.RawMessage(strconv.Itoa(s.nextID)), Method: method}
+	request.Params, _ = json.Marshal(params)
+	data, _ := json.Marshal(request)
+	if _, err := s.in.Write(append(data, '\n')); err != nil {
+		s.t.Fatal(err)
+	}
+	if !s.out.Scan() {
+		s.t.Fatalf("no response to %s: %v", method, s.out.Err())
+	}
+	var response message
+	if err := json.Unmarshal(s.out.Bytes(), &response); err != nil {
+		s.t.Fatal(err)
+	}
+	if string(response.ID) != string(request.ID) {
+		s.t.Fatalf("response id %s, want %s", response.ID, request.ID)
+	}
+	return &response
+}
+
+func (s *session) listTools() []string {
+	s.t.Helper()
+	response := s.request("tools/list", listToolsParams{})
+	var result listToolsResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		s.t.Fatal(err)
+	}
+	names := make([]string, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func (s *session) callTool(name, arguments string) (*CallToolResult, *RPCError) {
+	s.t.Helper()
+	response := s.request("tools/call", callToolParams{Name: name, Arguments: json.RawMessage(arguments)})
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		s.t.Fatal(err)
+	}
+	return &result, nil
+}
+
+// testRegistry holds echo, which is allowed, write, which asks, and remove,
+// which is denied.
+func testRegistry() *goAgent.ToolRegistry {
+	echo := func(inv *goAgent.ToolInvocation) (map[string]interface{}, error) {
+		var arguments map[string]interface{}
+		if err := inv.Bind(&arguments); err != nil {
+			return nil, err
+		}
+		return arguments, nil
+	}
+	parameters := *goAgent.NewToolParameters("object")
+	parameters.AddProperty("text", "string", "", nil, true)
+	tools := []*goAgent.Tool{
+		goAgent.NewTool("function", "echo", "echoes text", echo),
+		goAgent.NewTool("function", "write", "writes text", echo),
+		goAgent.NewTool("function", "remove", "removes text", echo),
+	}
+	for _, tool := range tools {
+		tool.Function.Parameters = parameters
+	}
+	tools[2].Policy = goAgent.ToolDeny
+	registry := goAgent.NewToolRegistry(tools...)
+	registry.SetPolicy("write", goAgent.ToolAsk)
+	return registry
+}
+
+func TestServerInitialize(t *testing.T) {
+	server := NewServer(testRegistry(), Implementation{Name: "test", Version: "1.0"})
+	server.Instructions = "use echo"
+	response := serve(t, server).request("initialize", initializeParams{ProtocolVersion: ProtocolVersion, ClientInfo: ClientInfo})
+	var result initializeResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ServerInfo.Name != "test" || result.ProtocolVersion != ProtocolVersion || result.Instructions != "use echo" {
+		t.Fatalf("unexpected initialize result %+v", result)
+	}
+}
+
+func TestServerListTools(t *testing.T) {
+	server := NewServer(testRegistry(), Implementation{Name: "test"})
+	if names := serve(t, server).listTools(); strings.Join(names, ",") != "echo" {
+		t.Fatalf("got %v without an approver, want only echo", names)
+	}
+
+	server = NewServer(testRegistry(), Implementation{Name: "test"})
+	server.Approver = goAgent.ApproverFunc(func(context.Context, *goAgent.Tool, *goAgent.ToolCall) (goAgent.Approval, error) {
+		return goAgent.Approval{Approved: true}, nil
+	})
+	session := serve(t, server)
+	if names := session.listTools(); strings.Join(names, ",") != "echo,write" {
+		t.Fatalf("got %v with an approver, want echo and write", names)
+	}
+
+	var result listToolsResult
+	if err := json.Unmarshal(session.request("tools/list", listToolsParams{}).Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(result.Tools[0].InputSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["type"] != "object" || len(schema["required"].([]interface{})) != 1 {
+		t.Fatalf("unexpected input schema %v", schema)
+	}
+}
+
+func TestServerCallTool(t *testing.T) {
+	session := serve(t, NewServer(testRegistry(), Implementation{Name: "test"}))
+
+	result, rpcError := session.callTool("echo", `{"text":"hi"}`)
+	if rpcError != nil {
+		t.Fatal(rpcError)
+	}
+	if result.IsError || result.StructuredContent["text"] != "hi" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, rpcError = session.callTool("echo", `{}`)
+	if rpcError != nil {
+		t.Fatal(rpcError)
+	}
+	if !result.IsError || !strings.Contains(result.Text(), "invalid_arguments") {
+		t.Fatalf("got %+v, want the invalid arguments reported", result)
+	}
+
+	for _, name := range []string{"missing", "remove", "write"} {
+		if _, rpcError = session.callTool(name, `{"text":"hi"}`); rpcError == nil || rpcError.Code != CodeInvalidParams {
+			t.Fatalf("calling %s gave %v, want an unknown tool error", name, rpcError)
+		}
+	}
+
+	if response := session.request("resources/list", nil); response.Error == nil || response.Error.Code != CodeMethodNotFound {
+		t.Fatalf("got %+v, want method not found", response)
+	}
+}
+
+func TestServerApprover(t *testing.T) {
+	var asked []string
+	server := NewServer(testRegistry(), Implementation{Name: "test"})
+	server.Approver = goAgent.ApproverFunc(func(_ context.Context, tool *goAgent.Tool, call *goAgent.ToolCall) (goAgent.Approval, error) {
+		asked = append(asked, string(call.Arguments))
+		if strings.Contains(string(call.Arguments), "secret") {
+			return goAgent.Approval{Reason: "no secrets"}, nil
+		}
+		return goAgent.Approval{Approved: true, Arguments: json.RawMessage(`{"text":"approved"}`)}, nil
+	})
+	session := serve(t, server)
+
+	result, rpcError := session.callTool("write", `{"text":"hi"}`)
+	if rpcError != nil {
+		t.Fatal(rpcError)
+	}
+	if result.IsError || result.StructuredContent["text"] != "approved" {
+		t.Fatalf("got %+v, want the call run with the approved arguments", result)
+	}
+
+	result, rpcError = session.callTool("write", `{"text":"secret"}`)
+	if rpcError != nil {
+		t.Fatal(rpcError)
+	}
+	if !result.IsError || !strings.Contains(result.Text(), "no secrets") {
+		t.Fatalf("got %+v, want the denial reported", result)
+	}
+
+	if _, rpcError = session.callTool("echo", `{"text":"hi"}`); rpcError != nil {
+		t.Fatal(rpcError)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("approver was asked %d times, want only for the 2 calls to write", len(asked))
+	}
+}
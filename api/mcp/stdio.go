@@ -0,0 +1,173 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxMessageSize bounds a single newline-delimited JSON-RPC message.
+const maxMessageSize = 16 << 20
+
+// stdioTransport talks to an MCP server process over newline-delimited JSON
+// on its stdin and stdout.
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan *message
+	done    chan struct{}
+	err     error // why the connection ended; set before done is closed
+}
+
+func startStdio(command string, args ...string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin of %s: %w", command, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout of %s: %w", command, err)
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %s: %w", command, err)
+	}
+
+	transport := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go transport.read(stdout)
+	return transport, nil
+}
+
+// read dispatches responses to their waiting requests until stdout closes.
+func (t *stdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var incoming message
+		if err := json.Unmarshal(scanner.Bytes(), &incoming); err != nil {
+			fmt.Fprintln(os.Stderr, "Ignoring invalid mcp message:", err)
+			continue
+		}
+		switch {
+		case incoming.isRequest():
+			t.answerServerRequest(&incoming)
+		case incoming.isNotification():
+			// logging and progress notifications are not used
+		default:
+			t.mu.Lock()
+			waiting, ok := t.pending[string(incoming.ID)]
+			delete(t.pending, string(incoming.ID))
+			t.mu.Unlock()
+			if ok {
+				waiting <- &incoming
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("mcp server closed the connection: %w", err)
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// answerServerRequest replies to requests the server sends us. Only ping is supported.
+func (t *stdioTransport) answerServerRequest(request *message) {
+	reply := &message{JSONRPC: jsonRPCVersion, ID: request.ID}
+	if request.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+	}
+	if err := t.write(reply); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to answer mcp server request:", err)
+	}
+}
+
+func (t *stdioTransport) write(outgoing *message) error {
+	data, err := json.Marshal(outgoing)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, request *message) (*message, error) {
+	waiting := make(chan *message, 1)
+	id := string(request.ID)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[id] = waiting
+	t.mu.Unlock()
+
+	forget := func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}
+	if err := t.write(request); err != nil {
+		forget()
+		return nil, fmt.Errorf("failed to send %s: %w", request.Method, err)
+	}
+
+	select {
+	case response := <-waiting:
+		return response, nil
+	case <-t.done:
+		forget()
+		return nil, t.err
+	case <-ctx.Done():
+		forget()
+		t.cancel(request.ID, ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+// cancel tells the server a request is no longer wanted.
+func (t *stdioTransport) cancel(id json.RawMessage, reason error) {
+	params, _ := json.Marshal(map[string]interface{}{"requestId": id, "reason": reason.Error()})
+	_ = t.write(&message{JSONRPC: jsonRPCVersion, Method: "notifications/cancelled", Params: params})
+}
+
+func (t *stdioTransport) notify(_ context.Context, notification *message) error {
+	return t.write(notification)
+}
+
+// Close closes the server's stdin and waits briefly for it to close stdout
+// before killing it. The process is only reaped once read has seen the end of
+// stdout, as exec.Cmd.Wait closes the pipe read is still using.
+func (t *stdioTransport) Close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.done
+	}
+	_ = t.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,139 @@
+// Command fakeserver is a small MCP server speaking newline-delimited JSON-RPC
+// on stdin and stdout, used by the client tests. It offers three tools over
+// two pages of tools/list:
+//
+//   - echo returns its text argument, and whether the client answered the
+//     ping sent during tools/list
+//   - fail returns a result with isError set
+//   - slow never answers, so callers must give up through their context
+//
+// The method "exit" makes it exit at once. With -linger it keeps running after
+// stdin closes, until it is killed.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   interface{}     `json:"error,omitempty"`
+}
+
+var (
+	writeMu sync.Mutex
+	pinged  bool
+)
+
+func write(outgoing *message) {
+	outgoing.JSONRPC = "2.0"
+	data, _ := json.Marshal(outgoing)
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	os.Stdout.Write(append(data, '\n'))
+}
+
+func main() {
+	linger := flag.Bool("linger", false, "keep running after stdin closes")
+	flag.Parse()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var incoming message
+		if err := json.Unmarshal(scanner.Bytes(), &incoming); err != nil {
+			continue
+		}
+		if incoming.Method == "" {
+			pinged = pinged || string(incoming.ID) == `"ping-1"`
+			continue
+		}
+		if len(incoming.ID) == 0 {
+			continue // notifications
+		}
+		if incoming.Method == "exit" {
+			os.Exit(1)
+		}
+		if reply := handle(&incoming); reply != nil {
+			write(reply)
+		}
+	}
+
+	// Output written while the client shuts down must still be read, so
+	// more than a pipe buffer of notifications is sent before exiting.
+	bye, _ := json.Marshal(map[string]interface{}{"level": "info", "data": strings.Repeat("bye ", 256)})
+	for i := 0; i < 256; i++ {
+		write(&message{Method: "notifications/message", Params: bye})
+	}
+	for *linger {
+		time.Sleep(time.Hour)
+	}
+}
+
+func handle(request *message) *message {
+	reply := &message{ID: request.ID}
+	switch request.Method {
+	case "initialize":
+		reply.Result = map[string]interface{}{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+			"instructions":    "be nice",
+		}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		if params.Cursor == "" {
+			write(&message{ID: json.RawMessage(`"ping-1"`), Method: "ping"})
+			write(&message{Method: "notifications/message", Params: json.RawMessage(`{"level":"info","data":"listing"}`)})
+			reply.Result = map[string]interface{}{"tools": []interface{}{tool("echo", `{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`)}, "nextCursor": "2"}
+		} else {
+			reply.Result = map[string]interface{}{"tools": []interface{}{
+				tool("fail", `{"type":"object"}`),
+				tool("slow", `{"type":"object"}`),
+			}}
+		}
+	case "tools/call":
+		var params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		switch params.Name {
+		case "echo":
+			reply.Result = map[string]interface{}{
+				"content":           []interface{}{map[string]interface{}{"type": "text", "text": params.Arguments.Text}},
+				"structuredContent": map[string]interface{}{"text": params.Arguments.Text, "pinged": pinged},
+			}
+		case "fail":
+			reply.Result = map[string]interface{}{
+				"content": []interface{}{map[string]interface{}{"type": "text", "text": "it broke"}},
+				"isError": true,
+			}
+		case "slow":
+			return nil
+		default:
+			reply.Error = map[string]interface{}{"code": -32602, "message": "unknown tool: " + params.Name}
+		}
+	default:
+		reply.Error = map[string]interface{}{"code": -32601, "message": "method not found: " + request.Method}
+	}
+	return reply
+}
+
+func tool(name, schema string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "description": "the " + name + " tool", "inputSchema": json.RawMessage(schema)}
+}
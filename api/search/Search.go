@@ -1,12 +1,16 @@
 package search
 
 import (
+	"context"
+	"embed"
+	"errors"
 	"fmt"
 	"github.com/EdersenC/goAgent"
 	"github.com/PuerkitoBio/goquery"
 	"io"
 	"math"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -14,15 +18,33 @@ import (
 )
 
 func (r *Result) ScrapeContentInto() error {
+	return r.ScrapeContentIntoContext(context.Background())
+}
+
+// ScrapeContentIntoContext is ScrapeContentInto bound to ctx; cancelling ctx
+// aborts both the page download and the embedding of its content. The page is
+// embedded by the embedding agent of the runtime carried by ctx.
+func (r *Result) ScrapeContentIntoContext(ctx context.Context) error {
+	return r.scrape(ctx, goAgent.RuntimeFrom(ctx).EmbeddingAgent())
+}
+
+func (r *Result) scrape(ctx context.Context, embedder *goAgent.Agent) error {
 	if !strings.HasPrefix(r.URL, "https://") {
 		return fmt.Errorf("skipping non-HTTPS URL: %s", r.URL)
 	}
+	if embedder == nil {
+		return fmt.Errorf("no embedding agent to embed %s", r.URL)
+	}
 
-	req, _ := http.NewRequest("GET", r.URL, nil)
+	downloadCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", r.URL, nil)
+	if err != nil {
+		return err
+	}
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := goAgent.RuntimeFrom(ctx).Client().Do(req)
 	if err != nil {
 		return err
 	}
@@ -45,11 +67,11 @@ func (r *Result) ScrapeContentInto() error {
 
 	r.Content = strings.TrimSpace(text)
 	if len(r.Content) > 0 {
-		embedding, err := goAgent.EmbeddingAgent.Embed(r.Content)
+		embedding, err := embedder.EmbedContext(ctx, r.Content)
+		r.EmbeddedContent = embedding
 		if err != nil {
 			return fmt.Errorf("embedding error: %w", err)
 		}
-		r.EmbeddedContent = embedding
 	} else {
 		return fmt.Errorf("no content found for URL: %s", r.URL)
 	}
@@ -101,9 +123,9 @@ func AverageComboScore(embeddings, match []*goAgent.EmbeddedContent) float64 {
 	return totalScore / float64(totalComparisons)
 }
 
-func rankByRelevance(results []*Result, query string, minimumThreshHold float64) ([]*Result, error) {
+func rankByRelevance(ctx context.Context, embedder *goAgent.Agent, results []*Result, query string, minimumThreshHold float64) ([]*Result, error) {
 	minimumThreshHold = minimumThreshHold / 100.0 // Convert to a 0-1 scale
-	embedding, err := goAgent.EmbeddingAgent.Embed(query)
+	embedding, err := embedder.EmbedContext(ctx, query)
 	rankedResults := make([]*Result, 0)
 	if err != nil {
 		return nil, fmt.Errorf("embedding error: %w", err)
@@ -134,11 +156,29 @@ var SearchExtractorPrompt string
 var SummaryTool = &goAgent.Tool{}
 var searchExtraction = &goAgent.Tool{}
 
+// defaultConfigs are the summary and extraction tools as released; a file of
+// the same name in goAgent.ConfigPath replaces either one.
+//
+//go:embed summarize.json searchExtraction.json
+var defaultConfigs embed.FS
+
 func init() {
-	goAgent.InitTool(SummaryTool, "summarize.json", nil)
-	goAgent.InitTool(searchExtraction, "searchExtraction.json", ReviewExtraction)
+	if err := LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load search tools:", err)
+	}
+}
+
+// LoadConfig (re)loads the summary and extraction tools and their prompts,
+// preferring files in goAgent.ConfigPath over the embedded defaults. It runs
+// on import; call it again after changing goAgent.ConfigPath.
+func LoadConfig() error {
+	err := errors.Join(
+		goAgent.InitToolFS(SummaryTool, defaultConfigs, "summarize.json", nil),
+		goAgent.InitToolFS(searchExtraction, defaultConfigs, "searchExtraction.json", ReviewExtraction),
+	)
 	SummaryPrompt = SummaryTool.AsPrompt(-1)
 	SearchExtractorPrompt = searchExtraction.AsPrompt(-1)
+	return err
 }
 
 // handlePage processes a single page of search results.
@@ -146,6 +186,7 @@ func init() {
 // scrapes, ranks, summarizes, and prints the results.
 //
 // Parameters:
+//   - ctx: cancels searching, scraping and the summarization workers.
 //   - engine: the search engine used to query.
 //   - query: the string query.
 //   - page: which page of results to retrieve.
@@ -153,24 +194,27 @@ func init() {
 //
 // Returns:
 //   - a slice of ranked Result pointers for the given page
-//   - an error if ranking or search fails.
-func handlePage(engine Engine, tracer *Trace, query string, page int, minimumRelevancy float64) ([]*Result, error) {
-	if cachedResults, found := cache[query]; found {
+//   - an error if ranking or search fails. When ctx is cancelled during
+//     summarization the ranked results are returned with whatever summaries
+//     finished, along with the context error.
+func handlePage(ctx context.Context, engine Engine, tracer *Trace, query string, page int, minimumRelevancy float64) ([]*Result, error) {
+	runtime := goAgent.RuntimeFrom(ctx)
+	if cachedResults, found := runtime.Cache.Get(cacheKey(query)); found {
 		fmt.Printf("\n\nUsing cached results for query: %s, page: %d\n\n", query, page)
-		return cachedResults, nil
+		return cachedResults.([]*Result), nil
 	}
 
-	results, err := engine.Search(query, page)
+	results, err := searchContext(ctx, engine, query, page)
 	if err != nil {
 		return nil, fmt.Errorf("search error: %w", err)
 	}
 	fmt.Println("Results for query:", query, "Page:", page, "Results:", len(results))
 
-	if err = scrapeAll(results); err != nil {
+	if err = scrapeAll(ctx, tracer.EmbeddingAgent, results); err != nil {
 		return nil, fmt.Errorf("scraping error: %w", err)
 	}
 
-	rankedResults, err := rankByRelevance(results, query, minimumRelevancy)
+	rankedResults, err := rankByRelevance(ctx, tracer.EmbeddingAgent, results, query, minimumRelevancy)
 	if err != nil {
 		return nil, fmt.Errorf("ranking error: %w", err)
 	}
@@ -180,28 +224,26 @@ func handlePage(engine Engine, tracer *Trace, query string, page int, minimumRel
 	fmt.Println("Ranked results for query:", query, "Page:", page, "Results:", len(rankedResults))
 	tracer.AttachBundle(NewBundle(query, NewPageDigest(results, "", rankedResults)))
 
-	agentTools := tracer.Chat.Agent.SwapRegistry(goAgent.NewToolRegistry(searchExtraction)) // Use only the SearchExtraction tool for this Chat
-	ToolRegistry := tracer.Chat.ToolRegistry.Swap(goAgent.NewToolRegistry(searchExtraction))
 	message := " IF not Relevant say 'No results found' and exit.\n\n"
 	message += "**YOU MUST USE USE TOOLS Provided**"
 	newExtraction := searchExtraction.Clone()
 	newExtraction.AddConstraints(message)
-	tracer.SummaryAgents = make([]*goAgent.Agent, 0)
-	tracer.SummaryAgents = append(tracer.SummaryAgents,
-		goAgent.SummaryAgent,
-		goAgent.SummaryAgent.WithPort("11436"),
-	)
-
 	var wg sync.WaitGroup
 	jobs := make(chan *Result, len(rankedResults)) // buffered channel to hold all jobs
 
 	// Start N workers
 	for w := 0; w < len(tracer.SummaryAgents); w++ {
 		go func(workerID int) {
+			// summariseChunk offers newExtraction per call, leaving the shared agent as it is
 			chat := goAgent.NewChat(tracer.SummaryAgents[workerID], goAgent.NewToolRegistry(newExtraction))
 			for result := range jobs { // pull jobs from the channel
+				if ctx.Err() != nil {
+					wg.Done() // drain remaining jobs once cancelled
+					continue
+				}
 				fmt.Printf("Worker %d summarizing: %s URL: %s\n", workerID, result.Title, result.URL)
-				result.Summarize(
+				result.SummarizeContext(
+					ctx,
 					chat, // worker-specific Chat instance
 					message,
 					chat.Agent.ContextPortion(75),
@@ -220,9 +262,7 @@ func handlePage(engine Engine, tracer *Trace, query string, page int, minimumRel
 	close(jobs) // Close channel so workers know there are no more jobs
 	wg.Wait()   // Wait for all jobs to finish
 
-	tracer.Chat.Agent.SwapRegistry(agentTools) // Restore original tools after summarization
-	tracer.Chat.ToolRegistry.Swap(ToolRegistry)
-	return rankedResults, nil
+	return rankedResults, ctx.Err()
 }
 
 // RunQuery executes a multipart search query using the provided engine.
@@ -240,19 +280,48 @@ func handlePage(engine Engine, tracer *Trace, query string, page int, minimumRel
 //   - a slice of ranked and summarized Result pointers
 //   - an error if something fails (non-fatal errors are logged, not returned).
 func RunQuery(engine Engine, query string, tracer *Trace, pages int, minimumRelevancy float64) error {
+	return RunQueryContext(context.Background(), engine, query, tracer, pages, minimumRelevancy)
+}
+
+// RunQueryContext is RunQuery bound to ctx. When ctx is cancelled it stops
+// after the current page and returns the context error; the tracer keeps the
+// bundles and summaries gathered up to that point.
+//
+// The search runs in tracer.Runtime, or else the runtime carried by ctx. Its
+// embedding agent and summarizer pool are copied into the tracer unless set,
+// so a query sticks to the same agents even if the runtime's mesh reloads.
+func RunQueryContext(ctx context.Context, engine Engine, query string, tracer *Trace, pages int, minimumRelevancy float64) error {
 	allRankedResults := make([]*Result, 0)
 	start := time.Now()
 
-	tracer.Chat.Agent = goAgent.SummaryAgent
+	runtime := tracer.Runtime
+	if runtime == nil {
+		runtime = goAgent.RuntimeFrom(ctx)
+	}
+	ctx = goAgent.WithRuntime(ctx, runtime)
+	if tracer.EmbeddingAgent == nil {
+		tracer.EmbeddingAgent = runtime.EmbeddingAgent()
+	}
+	if len(tracer.SummaryAgents) == 0 {
+		tracer.SummaryAgents = runtime.SummaryAgents()
+	}
+	if tracer.EmbeddingAgent == nil || len(tracer.SummaryAgents) == 0 {
+		return fmt.Errorf("query %q needs an embedding and a summary agent", query)
+	}
+	tracer.Chat.Agent = tracer.SummaryAgents[0]
 
 	for page := 1; page <= pages; page++ {
-		pageResults, err := handlePage(engine, tracer, query, page, minimumRelevancy)
+		pageResults, err := handlePage(ctx, engine, tracer, query, page, minimumRelevancy)
+		if ctx.Err() != nil {
+			tracer.Duration = time.Since(start).Milliseconds()
+			return fmt.Errorf("query %q interrupted after %d results: %w", query, len(allRankedResults)+len(pageResults), ctx.Err())
+		}
 		if err != nil {
 			fmt.Println("Error handling page:", err)
 			continue
 		}
 		allRankedResults = append(allRankedResults, pageResults...)
-		cache[query] = pageResults
+		runtime.Cache.Set(cacheKey(query), pageResults)
 	}
 	if len(allRankedResults) == 0 {
 		return fmt.Errorf("no results found for query: %s", query)
@@ -261,21 +330,46 @@ func RunQuery(engine Engine, query string, tracer *Trace, pages int, minimumRele
 	return nil
 }
 
+// Summarize lists the summaries of the ranked results gathered for each query.
 func (t *Trace) Summarize(chat *goAgent.Chat) string {
-	return ""
+	var sb strings.Builder
+	for _, bundle := range t.Bundle {
+		if bundle.PageDigest == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("Query: %s\n", bundle.Query))
+		for _, digest := range *bundle.PageDigest {
+			for _, result := range digest.ranked {
+				if result.getSummary() == "" {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("- %s (%s)\n%s\n", result.Title, result.URL, result.getSummary()))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if sb.Len() == 0 {
+		return "No results found."
+	}
+	return strings.TrimSpace(sb.String())
 }
 
 // scrapeAll iterates over search results and scrapes their content.
 // It logs individual scraping errors but continues processing the list.
 //
 // Parameters:
+//   - ctx: stops scraping the remaining results once cancelled.
+//   - embedder: the agent that embeds the scraped content.
 //   - results: a slice of pointers to Result structs.
 //
 // Returns:
 //   - an error if one or more scraping operations fail.
-func scrapeAll(results []*Result) error {
+func scrapeAll(ctx context.Context, embedder *goAgent.Agent, results []*Result) error {
 	for _, result := range results {
-		if err := result.ScrapeContentInto(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := result.scrape(ctx, embedder); err != nil {
 			fmt.Println("Error scraping content:", err)
 		}
 	}
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"fmt"
 	"github.com/EdersenC/goAgent"
 	"os"
@@ -33,8 +34,21 @@ type Citation struct {
 	Relevance float64 `json:"relevance"` //Todo Use embedding to calculate relevance
 }
 
+// extractionTools returns the tools offered while summarising: the chat's own
+// extraction tool, which may carry extra constraints, or the default one. They
+// replace the agent's tools for the call, so the agent itself, shared by other
+// workers, is never changed.
+func extractionTools(chat *goAgent.Chat) *goAgent.ToolRegistry {
+	if chat.ToolRegistry != nil {
+		if tool, ok := chat.ToolRegistry.GetTool(searchExtraction.Function.Name); ok {
+			return goAgent.NewToolRegistry(tool)
+		}
+	}
+	return goAgent.NewToolRegistry(searchExtraction)
+}
+
 // tries to summarise a single chunk; retries once when BindToolResult fails
-func summariseChunk(chunk, instructions string, maxContext int,
+func summariseChunk(ctx context.Context, chunk, instructions string, maxContext int,
 	chat *goAgent.Chat) (string, error) {
 
 	// shrink chunk if it still busts the context window
@@ -46,7 +60,7 @@ func summariseChunk(chunk, instructions string, maxContext int,
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		prompt := buildPrompt(instructions, chunk)
 		fmt.Println("Prompt TokenSize", goAgent.Tokenize(prompt))
-		response, err := chat.SendUserMessage(prompt, false)
+		response, err := chat.SendMessageWithTools(ctx, "user", "**User Prompt**:\n "+prompt, extractionTools(chat), false)
 		if err != nil {
 			chat.ClearConversation()
 			return "", err
@@ -76,10 +90,20 @@ func summariseChunk(chunk, instructions string, maxContext int,
 
 func ProcessChunks(chunks []string, chat *goAgent.Chat,
 	instructions string, maxContext int) []string {
+	return ProcessChunksContext(context.Background(), chunks, chat, instructions, maxContext)
+}
+
+// ProcessChunksContext summarises chunks until ctx is cancelled and returns
+// the summaries finished so far.
+func ProcessChunksContext(ctx context.Context, chunks []string, chat *goAgent.Chat,
+	instructions string, maxContext int) []string {
 
 	var results []string
 	for _, chunk := range chunks {
-		msg, err := summariseChunk(chunk, instructions, maxContext, chat)
+		if ctx.Err() != nil {
+			break
+		}
+		msg, err := summariseChunk(ctx, chunk, instructions, maxContext, chat)
 		if err != nil {
 			fmt.Println("Chunk failed:", err)
 			continue
@@ -89,12 +113,8 @@ func ProcessChunks(chunks []string, chat *goAgent.Chat,
 	return results
 }
 
-func ReviewExtraction(response map[string]interface{}, chat *goAgent.Chat) (map[string]interface{}, error) {
-	arguments, ok := response["arguments"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("arguments not found in response")
-	}
-
+func ReviewExtraction(inv *goAgent.ToolInvocation) (map[string]interface{}, error) {
+	arguments := inv.Arguments()
 	citations, ok := arguments["citations"].([]interface{})
 	if !ok {
 		return nil, fmt.Errorf("citations not found in arguments")
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"fmt"
 	"github.com/EdersenC/goAgent"
 	"strings"
@@ -17,6 +18,7 @@ type Trace struct {
 	Chat           *goAgent.Chat
 	SummaryAgents  []*goAgent.Agent
 	EmbeddingAgent *goAgent.Agent
+	Runtime        *goAgent.Runtime // nil uses the runtime of the query's context
 }
 
 func (t *Trace) FormatDuration() string {
@@ -83,6 +85,12 @@ func (r *Result) getSummary() string {
 }
 
 func (r *Result) Summarize(chat *goAgent.Chat, instructions string, maxContext int) string {
+	return r.SummarizeContext(context.Background(), chat, instructions, maxContext)
+}
+
+// SummarizeContext is Summarize bound to ctx. If ctx is cancelled part way
+// the summary holds the chunks that finished.
+func (r *Result) SummarizeContext(ctx context.Context, chat *goAgent.Chat, instructions string, maxContext int) string {
 	if r.getSummary() != "" {
 		return r.getSummary()
 	}
@@ -96,14 +104,14 @@ func (r *Result) Summarize(chat *goAgent.Chat, instructions string, maxContext i
 		return "No content to summarize"
 	}
 	startTime := time.Now()
-	processedChunks := ProcessChunks(chunks, chat, instructions, maxContext)
+	processedChunks := ProcessChunksContext(ctx, chunks, chat, instructions, maxContext)
 	var summary strings.Builder
 	summary.WriteString(strings.Join(processedChunks, "\n\n"))
 
-	for goAgent.Tokenize(chat.Agent.SystemPrompt+summary.String()) > maxContext {
+	for ctx.Err() == nil && goAgent.Tokenize(chat.Agent.SystemPrompt+summary.String()) > maxContext {
 		fmt.Println("Summary too long, chunking again")
 		summary.Reset()
-		summary.WriteString(strings.Join(ProcessChunks(processedChunks, chat, instructions, maxContext), "\n\n"))
+		summary.WriteString(strings.Join(ProcessChunksContext(ctx, processedChunks, chat, instructions, maxContext), "\n\n"))
 	}
 	r.NewSummary(summary.String(), time.Since(startTime).Milliseconds())
 	fmt.Println("\n\nSummary duration:", r.FormatDuration())
@@ -216,4 +224,24 @@ type Engine interface {
 	Search(query string, page int) ([]*Result, error)
 }
 
-var cache = make(map[string][]*Result)
+// ContextEngine is implemented by engines whose searches can be cancelled.
+type ContextEngine interface {
+	Engine
+	SearchContext(ctx context.Context, query string, page int) ([]*Result, error)
+}
+
+// searchContext runs the search with ctx when the engine supports it.
+func searchContext(ctx context.Context, engine Engine, query string, page int) ([]*Result, error) {
+	if contextEngine, ok := engine.(ContextEngine); ok {
+		return contextEngine.SearchContext(ctx, query, page)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return engine.Search(query, page)
+}
+
+// cacheKey is where the results of query are kept in the runtime's cache.
+func cacheKey(query string) string {
+	return "search:" + query
+}
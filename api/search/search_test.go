@@ -0,0 +1,149 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/EdersenC/goAgent"
+)
+
+// pageEngine returns one result per page served by the test server.
+type pageEngine struct {
+	baseURL string
+	pages   int
+}
+
+func (e pageEngine) Search(query string, page int) ([]*Result, error) {
+	results := make([]*Result, e.pages)
+	for i := range results {
+		results[i] = &Result{Title: fmt.Sprintf("%s %d", query, i), URL: fmt.Sprintf("%s/%d", e.baseURL, i)}
+	}
+	return results, nil
+}
+
+// fakeOllama answers embeddings with a fixed vector and every chat with a call
+// to the extraction tool, checking that it is the only tool offered.
+func fakeOllama(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/api/embeddings" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float64{0.5, 0.5, 0.5}})
+			return
+		}
+		tools, _ := request["tools"].([]interface{})
+		if len(tools) != 1 {
+			t.Errorf("summarizer was offered %d tools, want the extraction tool only", len(tools))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": request["model"],
+			"done":  true,
+			"message": map[string]interface{}{
+				"role": "assistant",
+				"tool_calls": []interface{}{map[string]interface{}{"function": map[string]interface{}{
+					"name": searchExtraction.Function.Name,
+					"arguments": map[string]interface{}{
+						"summary":   "a summary",
+						"citations": []interface{}{map[string]interface{}{"content": "quote", "url": "https://example.com", "relevance": 1}},
+					},
+				}}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestConcurrentSummaries runs two queries at once on one runtime. Their
+// summary workers share the runtime's agents, so `go test -race` checks that
+// summarizing leaves them alone.
+func TestConcurrentSummaries(t *testing.T) {
+	pages := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body><p>Content of %s.</p></body></html>", r.URL.Path)
+	}))
+	defer pages.Close()
+	ollama := fakeOllama(t)
+
+	provider := &goAgent.Provider{Type: "ollama", BaseUrl: ollama.URL}
+	summarizer := &goAgent.Agent{
+		Name:     "Summarizer",
+		Model:    goAgent.Model{Name: "summarizer", ContextWindow: 4096},
+		Provider: provider,
+		Tools:    goAgent.NewToolRegistry(searchExtraction),
+	}
+	embedder := &goAgent.Agent{Name: "Embedder", Model: goAgent.Model{Name: "embedder"}, Provider: provider.Clone()}
+	runtime := goAgent.NewRuntime(goAgent.NewStaticMesh(map[string]*goAgent.Agent{"Summarizer": summarizer, "Embedder": embedder}))
+	runtime.HTTPClient = pages.Client()
+	shared := runtime.SummaryAgent()
+	extraction, _ := shared.GetTools().GetTool(searchExtraction.Function.Name)
+	constraints := len(extraction.Function.Constraints)
+
+	var wg sync.WaitGroup
+	traces := make([]*Trace, 2)
+	for i := range traces {
+		query := fmt.Sprintf("query %d", i)
+		traces[i] = NewTrace("summarize this ", query)
+		traces[i].Runtime = runtime
+		traces[i].Chat = goAgent.NewChat(shared, goAgent.NewToolRegistry())
+		traces[i].SummaryAgents = []*goAgent.Agent{shared, shared, shared.WithPort("")} // workers sharing one agent
+		wg.Add(1)
+		go func(trace *Trace) {
+			defer wg.Done()
+			if err := RunQueryContext(context.Background(), pageEngine{baseURL: pages.URL, pages: 4}, query, trace, 1, 0); err != nil {
+				t.Error(err)
+			}
+		}(traces[i])
+	}
+	wg.Wait()
+
+	for i, trace := range traces {
+		if summary := trace.Summarize(nil); summary == "No results found." {
+			t.Errorf("query %d: no summaries", i)
+		}
+	}
+	if tools := shared.GetTools().GetTools(); len(tools) != 1 || tools[0] != extraction {
+		t.Fatalf("summarizing changed the shared agent's tools: %v", tools)
+	}
+	if len(extraction.Function.Constraints) != constraints {
+		t.Fatal("summarizing changed the extraction tool")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	previous := goAgent.ConfigPath
+	goAgent.ConfigPath = []string{dir}
+	t.Cleanup(func() {
+		goAgent.ConfigPath = previous
+		_ = LoadConfig()
+	})
+
+	if err := LoadConfig(); err != nil {
+		t.Fatalf("the embedded defaults did not load: %v", err)
+	}
+	if SummaryTool.Function.Name == "" || searchExtraction.Function.FunctionCall == nil || SummaryPrompt == "" {
+		t.Fatal("the embedded defaults left the tools unset")
+	}
+
+	override := `{"type":"function","function":{"name":"overridden","description":"from the config path"}}`
+	if err := os.WriteFile(filepath.Join(dir, "summarize.json"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if SummaryTool.Function.Name != "overridden" || !strings.Contains(SummaryPrompt, "from the config path") {
+		t.Fatalf("the file on the config path was not preferred: %+v", SummaryTool.Function)
+	}
+}
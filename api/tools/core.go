@@ -1,7 +1,9 @@
 package tools
 
 import (
-	"encoding/json"
+	"context"
+	"embed"
+	"errors"
 	"fmt"
 	"github.com/EdersenC/goAgent"
 	"github.com/EdersenC/goAgent/api/search"
@@ -9,7 +11,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 )
@@ -17,23 +19,37 @@ import (
 var SearchTool = &goAgent.Tool{}
 var ResponseTool = &goAgent.Tool{}
 
+// defaultConfigs ships search.json and respond.json with the package.
+//
+//go:embed search.json respond.json
+var defaultConfigs embed.FS
+
 // todo refactor so that we add more fields to engine interface
 func init() {
-	goAgent.InitTool(SearchTool, "search.json", initSearch)
-	goAgent.InitTool(ResponseTool, "respond.json", PrintResponse)
+	if err := LoadConfig(); err != nil {
+		// init also runs under "goagent mcp serve", where stdout is the protocol.
+		fmt.Fprintln(os.Stderr, "Failed to load tools:", err)
+	}
 }
 
-func PrintResponse(response map[string]interface{}, chat *goAgent.Chat) (map[string]interface{}, error) {
-	arguments, ok := response["arguments"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid arguments format")
-	}
+// LoadConfig (re)loads the tool definitions, preferring files in
+// goAgent.ConfigPath over the embedded defaults. It runs on import; call it
+// again after changing goAgent.ConfigPath.
+func LoadConfig() error {
+	return errors.Join(
+		goAgent.InitToolFS(SearchTool, defaultConfigs, "search.json", initSearch),
+		goAgent.InitToolFS(ResponseTool, defaultConfigs, "respond.json", PrintResponse),
+	)
+}
+
+func PrintResponse(inv *goAgent.ToolInvocation) (map[string]interface{}, error) {
+	arguments := inv.Arguments()
 	message, ok := arguments["message"].(string)
 	if !ok {
 		return nil, fmt.Errorf("message not found in arguments")
 	}
 	fmt.Println("Message:", message)
-	return response, nil
+	return arguments, nil
 }
 
 type DuckDuckGo struct {
@@ -45,18 +61,29 @@ func (d DuckDuckGo) Trace() *search.Trace {
 }
 
 func (d DuckDuckGo) Search(query string, page int) ([]*search.Result, error) {
+	return d.SearchContext(context.Background(), query, page)
+}
+
+func (d DuckDuckGo) SearchContext(ctx context.Context, query string, page int) ([]*search.Result, error) {
 	offset := (page - 1) * 10
 	data := url.Values{
 		"q": {query},
 		"s": {fmt.Sprintf("%d", offset)},
 	}
 
-	req, _ := http.NewRequest("POST", "https://html.duckduckgo.com/html/", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://html.duckduckgo.com/html/", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 
-	client := &http.Client{}
-	time.Sleep(1 * time.Second)
+	client := goAgent.RuntimeFrom(ctx).Client()
+	select {
+	case <-time.After(1 * time.Second):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -90,18 +117,12 @@ func (d DuckDuckGo) Search(query string, page int) ([]*search.Result, error) {
 	return results, nil
 }
 
+// Relevancy is the score (0-100) search results need to be kept when the
+// runtime does not set Runtime.MinRelevancy.
 var Relevancy = 50.0
 
-func initSearch(request map[string]interface{}, chat *goAgent.Chat) (map[string]interface{}, error) {
-	arguments, err := extractArguments(request)
-	if err != nil {
-		return nil, err
-	}
-
-	prompt, ok := request["prompt"].(string)
-	if !ok || prompt == "" {
-		return nil, fmt.Errorf("prompt is required and must be a string")
-	}
+func initSearch(inv *goAgent.ToolInvocation) (map[string]interface{}, error) {
+	arguments := inv.Arguments()
 
 	reason, ok := arguments["reason"].(string)
 	if !ok {
@@ -112,6 +133,12 @@ func initSearch(request map[string]interface{}, chat *goAgent.Chat) (map[string]
 	if err != nil {
 		return nil, err
 	}
+
+	// Calls from outside a chat, such as MCP hosts, have no user prompt to rank results against.
+	prompt := inv.Prompt
+	if prompt == "" {
+		prompt = strings.TrimSpace(reason + "\n" + strings.Join(queries, "\n"))
+	}
 	fmt.Println("Total Queries:", len(queries))
 
 	pageNumber, err := parsePageNumber(arguments["page"])
@@ -121,51 +148,56 @@ func initSearch(request map[string]interface{}, chat *goAgent.Chat) (map[string]
 
 	engine := DuckDuckGo{}
 
-	traceChat := goAgent.NewChat(chat.Agent, goAgent.NewToolRegistry())
-	trace := executeQueries(engine, traceChat, queries, prompt, reason, pageNumber)
-	instruction := "Search results Completed."
-
-	summarySection := fmt.Sprintf("**Search Summary**:\n%s", trace.Summarize(chat))
-
-	fullMessage := instruction + "\n\n" + summarySection
-
-	result, err := chat.SendMessage("user", fullMessage, false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send assistant message: %w", err)
-	}
-	result.PrintThoughts()
-	result.PrintContent()
-	return map[string]interface{}{"summary": trace}, nil
-}
-
-func extractArguments(args map[string]interface{}) (map[string]interface{}, error) {
-	arguments, ok := args["arguments"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid query parameter")
-	}
-	return arguments, nil
+	ctx := inv.Context
+	runtime := inv.Runtime()
+	var traceChat *goAgent.Chat // executeQueries falls back to the planner without a calling agent
+	if inv.Chat != nil && inv.Chat.Agent != nil {
+		traceChat = goAgent.NewChat(inv.Chat.Agent, goAgent.NewToolRegistry())
+		traceChat.Runtime = runtime
+	}
+	trace := executeQueries(ctx, runtime, engine, traceChat, queries, prompt, reason, pageNumber)
+
+	// The result goes back to the model as a tool message; Chat.Run resends the conversation.
+	return map[string]interface{}{
+		"status":   "Search results Completed.",
+		"queries":  queries,
+		"summary":  trace.Summarize(inv.Chat),
+		"duration": trace.FormatDuration(),
+	}, nil
 }
 
+// parsePageNumber reads the optional page argument; Tool.Call has already
+// validated it as an integer, converting numeric strings.
 func parsePageNumber(raw interface{}) (int, error) {
-	pageStr, ok := raw.(string)
-	if !ok || pageStr == "" {
+	page, ok := raw.(float64)
+	if !ok {
 		return 1, nil // default to page 1
 	}
-	pageNumber, err := strconv.Atoi(pageStr)
-	if err != nil {
+	if page < 1 {
 		return 0, fmt.Errorf("invalid page parameter")
 	}
-	return pageNumber, nil
+	return int(page), nil
 }
 
-func executeQueries(engine search.Engine, chat *goAgent.Chat, queries []string, prompt, reason string, pageNumber int) *search.Trace {
+// executeQueries runs each query in turn in runtime, stopping early if ctx is
+// cancelled. The returned trace holds whatever was gathered before that.
+func executeQueries(ctx context.Context, runtime *goAgent.Runtime, engine search.Engine, chat *goAgent.Chat, queries []string, prompt, reason string, pageNumber int) *search.Trace {
 	if chat == nil {
-		chat = goAgent.NewChat(goAgent.PlannerAgent, goAgent.NewToolRegistry())
+		chat = goAgent.NewChat(runtime.PlannerAgent(), goAgent.NewToolRegistry())
+		chat.Runtime = runtime
+	}
+	relevancy := runtime.MinRelevancy
+	if relevancy <= 0 {
+		relevancy = Relevancy
 	}
 	tracer := search.NewTrace(prompt, reason)
 	tracer.Chat = chat
+	tracer.Runtime = runtime
 	for _, query := range queries {
-		err := search.RunQuery(engine, query, tracer, pageNumber, Relevancy)
+		if ctx.Err() != nil {
+			break
+		}
+		err := search.RunQueryContext(ctx, engine, query, tracer, pageNumber, relevancy)
 		if err != nil {
 			continue
 		}
@@ -174,45 +206,16 @@ func executeQueries(engine search.Engine, chat *goAgent.Chat, queries []string,
 	return tracer
 }
 
+// normalizeQueries converts the queries argument, which Tool.Call has already
+// validated as an array (decoding stringified lists), to strings.
 func normalizeQueries(raw interface{}) ([]string, error) {
-	switch v := raw.(type) {
-
-	case []interface{}:
-		queries := make([]string, len(v))
-		for i, val := range v {
-			queries[i] = fmt.Sprintf("%v", val)
-		}
-		return queries, nil
-
-	case []string:
-		return v, nil
-
-	case string:
-		trimmed := strings.TrimSpace(v)
-
-		// Fix common format error: single-quoted list string
-		if strings.HasPrefix(trimmed, "['") && strings.HasSuffix(trimmed, "']") {
-			trimmed = strings.ReplaceAll(trimmed, `'`, `"`) // convert to valid JSON
-		}
-
-		// First pass: parse into []string
-		var parsed []string
-		if err := json.Unmarshal([]byte(trimmed), &parsed); err == nil {
-			return parsed, nil
-		}
-
-		// Second pass: see if it's a stringifies list (i.e. a JSON string containing JSON)
-		var embedded string
-		if err := json.Unmarshal([]byte(trimmed), &embedded); err == nil {
-			var nestedParsed []string
-			if err = json.Unmarshal([]byte(embedded), &nestedParsed); err == nil {
-				return nestedParsed, nil
-			}
-		}
-
-		return nil, fmt.Errorf("invalid query format or unsupported string structure")
-
-	default:
+	values, ok := raw.([]interface{})
+	if !ok {
 		return nil, fmt.Errorf("unexpected queries type: %T", raw)
 	}
+	queries := make([]string, len(values))
+	for i, value := range values {
+		queries[i] = fmt.Sprintf("%v", value)
+	}
+	return queries, nil
 }
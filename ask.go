@@ -0,0 +1,100 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultAskRetries is how many times Ask re-prompts the model after a reply
+// that does not match the requested type.
+var DefaultAskRetries = 2
+
+// Ask sends prompt as a user message and binds the model's reply to a T.
+// See AskContext.
+func Ask[T any](chat *Chat, prompt string) (T, error) {
+	return AskContext[T](context.Background(), chat, prompt, DefaultAskRetries)
+}
+
+// AskContext asks the model for a structured reply. The JSON Schema of T is
+// sent with the request (Ollama's format, OpenAI's response_format or a system
+// instruction for Anthropic) and the agent's tools are withheld for the call.
+// Replies that are not valid JSON for the schema are answered with the
+// validation error and asked for again, up to retries times.
+func AskContext[T any](ctx context.Context, chat *Chat, prompt string, retries int) (T, error) {
+	var result T
+	schema := SchemaFor[T]()
+	call := callOptions{format: schema, noTools: true}
+
+	content := "**User Prompt**:\n " + prompt
+	for attempt := 0; ; attempt++ {
+		response, err := chat.sendMessage(ctx, "user", content, call)
+		if err != nil {
+			return result, err
+		}
+
+		err = bindStructured(response.Message.Content, schema, &result)
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= retries {
+			return result, fmt.Errorf("invalid structured reply after %d attempts: %w", attempt+1, err)
+		}
+		fmt.Println("Invalid structured reply, asking again:", err)
+		content = fmt.Sprintf(
+			"Your previous reply was invalid: %v\n\nReply again with only a JSON value that matches this JSON Schema:\n%s",
+			err, schemaJSON(schema),
+		)
+	}
+}
+
+// bindStructured validates a JSON reply against schema and decodes it into target.
+func bindStructured(content string, schema map[string]interface{}, target interface{}) error {
+	data := extractJSON(content)
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	if err := ValidateSchema(value, schema); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(data), target); err != nil {
+		return fmt.Errorf("failed to unmarshal reply: %w", err)
+	}
+	return nil
+}
+
+// extractJSON strips the markdown code fences and surrounding prose that
+// models tend to wrap JSON replies in.
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+		return strings.TrimSpace(content)
+	}
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return content
+	}
+	end := strings.LastIndexAny(content, "}]")
+	if end < start {
+		return content[start:]
+	}
+	return content[start : end+1]
+}
+
+// formatInstruction tells backends without a native JSON mode what to reply with.
+func formatInstruction(schema map[string]interface{}) string {
+	return "Reply with only a JSON value, without any other text, that matches this JSON Schema:\n" + schemaJSON(schema)
+}
+
+func schemaJSON(schema map[string]interface{}) string {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
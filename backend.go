@@ -0,0 +1,157 @@
+package goAgent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ErrNotSupported is returned by a Backend for operations its provider does not offer.
+var ErrNotSupported = errors.New("operation not supported by backend")
+
+// ErrModelNotFound is returned by ShowModel when the provider does not serve the model.
+var ErrModelNotFound = errors.New("model not found")
+
+// Backend translates goAgent requests into a provider's wire format and back.
+// Each Provider resolves to a Backend through its Type field. Every call must
+// abort its in-flight HTTP request when ctx is cancelled.
+type Backend interface {
+	// Chat sends the conversation and returns the model's reply.
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	// Generate runs a single raw completion for the given prompt.
+	Generate(ctx context.Context, req *GenerateRequest) (*ChatResponse, error)
+	// Embed returns the embedding vector for content.
+	Embed(ctx context.Context, model Model, content string) ([]float64, error)
+	// Tokenize returns the provider's token ids for content.
+	Tokenize(ctx context.Context, model Model, content string) ([]int, error)
+	// ListModels returns the models the provider currently serves.
+	ListModels(ctx context.Context) ([]*ModelInfo, error)
+	// ShowModel returns what the provider reports about a single model.
+	ShowModel(ctx context.Context, name string) (*ModelInfo, error)
+}
+
+// ChatRequest is the backend-neutral description of a chat call.
+// When Stream is set the backend streams the reply, reporting content and
+// thinking deltas to OnEvent, and still returns the assembled response.
+// When Format is set the reply content must be JSON matching that JSON Schema.
+type ChatRequest struct {
+	Model    Model
+	Messages []*Message
+	Tools    []*Tool
+	Format   map[string]interface{}
+	Stream   bool
+	OnEvent  StreamHandler
+}
+
+// GenerateRequest is the backend-neutral description of a raw completion call.
+type GenerateRequest struct {
+	Model  Model
+	Prompt string
+	System string
+}
+
+// Capabilities a provider can report for a model.
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityThinking   = "thinking"
+	CapabilityEmbedding  = "embedding"
+)
+
+// ModelInfo describes a model reported by a provider.
+// Zero lengths and nil Capabilities mean the provider did not say.
+type ModelInfo struct {
+	Name            string   `json:"name"`
+	Size            int64    `json:"size,omitempty"`
+	ContextLength   int      `json:"contextLength,omitempty"`
+	EmbeddingLength int      `json:"embeddingLength,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+}
+
+// Supports reports whether the model has the given capability.
+func (m *ModelInfo) Supports(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+// findModel returns the model called name from a provider's model list.
+// A missing tag matches ":latest", as it does in Ollama.
+func findModel(models []*ModelInfo, name string) (*ModelInfo, error) {
+	for _, model := range models {
+		if model.Name == name || model.Name == name+":latest" {
+			return model, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrModelNotFound, name)
+}
+
+// BackendFactory builds a Backend bound to the given provider.
+type BackendFactory func(provider *Provider) Backend
+
+const (
+	BackendOllama = "ollama"
+)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		BackendOllama:    newOllamaBackend,
+		BackendOpenAI:    newOpenAIBackend,
+		BackendAnthropic: newAnthropicBackend,
+	}
+)
+
+// RegisterBackend makes a backend available to providers whose Type matches name.
+// Registering an existing name replaces the previous factory.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// Backends returns the names of all registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Backend resolves the provider to its Backend implementation.
+// An empty Type defaults to Ollama to stay compatible with older agent files.
+func (p *Provider) Backend() (Backend, error) {
+	if p == nil {
+		return nil, fmt.Errorf("provider is not set")
+	}
+	backendType := p.Type
+	if backendType == "" {
+		backendType = BackendOllama
+	}
+	backendsMu.RLock()
+	factory, ok := backends[backendType]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q (available: %v)", backendType, Backends())
+	}
+	return factory(p), nil
+}
+
+// Backend resolves the agent's provider to its Backend implementation,
+// retrying transient failures according to the agent's retry policy.
+func (a *Agent) Backend() (Backend, error) {
+	if a.Provider == nil {
+		return nil, fmt.Errorf("agent %s has no provider", a.Name)
+	}
+	backend, err := a.Provider.Backend()
+	if err != nil {
+		return nil, err
+	}
+	return withRetry(backend, a.Retry), nil
+}
@@ -0,0 +1,206 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProviderBackend(t *testing.T) {
+	tests := []struct {
+		provider *Provider
+		want     string // the backend's type, or the error
+	}{
+		{provider: &Provider{}, want: "*goAgent.ollamaBackend"},
+		{provider: &Provider{Type: BackendOllama}, want: "*goAgent.ollamaBackend"},
+		{provider: &Provider{Type: BackendOpenAI}, want: "*goAgent.openAIBackend"},
+		{provider: &Provider{Type: BackendAnthropic}, want: "*goAgent.anthropicBackend"},
+		{provider: &Provider{Type: "pigeon"}, want: `unknown provider type "pigeon" (available: [anthropic ollama openai])`},
+		{want: "provider is not set"},
+	}
+	for _, test := range tests {
+		backend, err := test.provider.Backend()
+		got := fmt.Sprintf("%T", backend)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("provider %+v resolved to %s, want %s", test.provider, got, test.want)
+		}
+	}
+	if _, err := (&Agent{Name: "Lost"}).Backend(); err == nil || err.Error() != "agent Lost has no provider" {
+		t.Fatalf("got %v for an agent without provider", err)
+	}
+}
+
+// recordingBackend answers every chat with "recorded" and keeps the requests.
+type recordingBackend struct {
+	requests []*ChatRequest
+}
+
+func (r *recordingBackend) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	r.requests = append(r.requests, req)
+	return &ChatResponse{Model: req.Model.Name, Done: true, Message: *NewMessage("assistant", "recorded")}, nil
+}
+
+func (r *recordingBackend) Generate(context.Context, *GenerateRequest) (*ChatResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *recordingBackend) Embed(context.Context, Model, string) ([]float64, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *recordingBackend) Tokenize(context.Context, Model, string) ([]int, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *recordingBackend) ListModels(context.Context) ([]*ModelInfo, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *recordingBackend) ShowModel(context.Context, string) (*ModelInfo, error) {
+	return nil, ErrNotSupported
+}
+
+func TestRegisterBackend(t *testing.T) {
+	recorder := &recordingBackend{}
+	var provider *Provider
+	RegisterBackend("recording", func(p *Provider) Backend {
+		provider = p
+		return recorder
+	})
+	t.Cleanup(func() {
+		backendsMu.Lock()
+		delete(backends, "recording")
+		backendsMu.Unlock()
+	})
+	if !slices.Contains(Backends(), "recording") || !slices.IsSorted(Backends()) {
+		t.Fatalf("got backends %v, want them sorted with recording", Backends())
+	}
+
+	agent := &Agent{Name: "Recorded", Model: Model{Name: "tape", ContextWindow: 4096}, Provider: &Provider{Type: "recording"}}
+	response, err := NewChat(agent, nil).SendMessage("user", "hi", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Message.Content != "recorded" || provider != agent.Provider {
+		t.Fatalf("got %+v from provider %p, want the recording backend bound to %p", response, provider, agent.Provider)
+	}
+	if len(recorder.requests) != 1 || recorder.requests[0].Model.Name != "tape" || !strings.HasSuffix(strings.TrimSpace(recorder.requests[0].Messages[0].Content), "hi") {
+		t.Fatalf("unexpected requests %+v", recorder.requests)
+	}
+}
+
+func TestOllamaChatRequest(t *testing.T) {
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"role": "assistant", "content": "ok"}
+	})
+	lookup := NewTool("function", "lookup", "looks things up", nil)
+	lookup.Function.Parameters.AddProperty("q", "string", "the query", nil, true)
+	if _, err := NewChat(fake.agent(lookup), nil).SendMessage("user", "hi", false); err != nil {
+		t.Fatal(err)
+	}
+
+	request := fake.requests[0]
+	messages := request["messages"].([]interface{})
+	if request["model"] != "fake" || request["stream"] != false || len(messages) != 1 || !strings.HasSuffix(strings.TrimSpace(messages[0].(map[string]interface{})["content"].(string)), "hi") {
+		t.Fatalf("unexpected request %v", request)
+	}
+	tool := request["tools"].([]interface{})[0].(map[string]interface{})
+	function := tool["function"].(map[string]interface{})
+	parameters := function["parameters"].(map[string]interface{})
+	if tool["type"] != "function" || function["name"] != "lookup" || parameters["required"].([]interface{})[0] != "q" {
+		t.Fatalf("unexpected tool %v", tool)
+	}
+}
+
+// ollamaEndpoint serves reply at one path and records the request it got.
+func ollamaEndpoint(t *testing.T, path, reply string, request *map[string]interface{}) *Provider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	return &Provider{Type: BackendOllama, BaseUrl: server.URL}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	for path, reply := range map[string]string{
+		ollamaEmbedEndpoint: `{"embedding":[0.5,1]}`,
+		"/api/embed":        `{"embeddings":[[0.5,1]]}`,
+	} {
+		var request map[string]interface{}
+		provider := ollamaEndpoint(t, path, reply, &request)
+		provider.EmbeddingEndpoint = path
+		embedding, err := newOllamaBackend(provider).Embed(context.Background(), Model{Name: "embedder"}, "text")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(embedding, []float64{0.5, 1}) {
+			t.Errorf("%s: got %v", path, embedding)
+		}
+		if request["model"] != "embedder" || request["prompt"] != "text" || request["input"] != "text" {
+			t.Errorf("%s: unexpected request %v", path, request)
+		}
+	}
+}
+
+func TestOllamaGenerate(t *testing.T) {
+	var request map[string]interface{}
+	provider := ollamaEndpoint(t, ollamaGenerateEndpoint, `{"model":"fake","response":"42","done":true}`, &request)
+	response, err := newOllamaBackend(provider).Generate(context.Background(), &GenerateRequest{Model: Model{Name: "fake"}, Prompt: "answer", System: "be brief"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Response != "42" || !response.Done {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if request["prompt"] != "answer" || request["system"] != "be brief" || request["stream"] != false {
+		t.Fatalf("unexpected request %v", request)
+	}
+}
+
+func TestOllamaTokenize(t *testing.T) {
+	if _, err := newOllamaBackend(&Provider{}).Tokenize(context.Background(), Model{Name: "fake"}, "text"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v without a tokenize endpoint, want ErrNotSupported", err)
+	}
+	var request map[string]interface{}
+	provider := ollamaEndpoint(t, "/tokenize", `{"tokens":[1,2,3]}`, &request)
+	provider.TokenizeEndpoint = "/tokenize"
+	tokens, err := newOllamaBackend(provider).Tokenize(context.Background(), Model{Name: "fake"}, "text")
+	if err != nil || !slices.Equal(tokens, []int{1, 2, 3}) || request["content"] != "text" {
+		t.Fatalf("got %v, %v for request %v", tokens, err, request)
+	}
+}
+
+func TestEndpointUrl(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		endpoint string
+		want     string
+	}{
+		{Provider{BaseUrl: "http://localhost"}, "", "http://localhost/fallback"},
+		{Provider{BaseUrl: "http://localhost", Port: "11434"}, "", "http://localhost:11434/fallback"},
+		{Provider{BaseUrl: "http://localhost", Port: "11434"}, "/custom", "http://localhost:11434/custom"},
+	}
+	for _, test := range tests {
+		if got := test.provider.endpointUrl(test.endpoint, "/fallback"); got != test.want {
+			t.Errorf("endpointUrl(%q) = %s, want %s", test.endpoint, got, test.want)
+		}
+	}
+}
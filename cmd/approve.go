@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/EdersenC/goAgent"
+	"strings"
+)
+
+// promptApprover asks on the terminal before a tool with the "ask" policy runs.
+// It shares the chat loop's scanner so no input is lost between the two.
+type promptApprover struct {
+	scanner *bufio.Scanner
+}
+
+func (p promptApprover) Approve(ctx context.Context, tool *goAgent.Tool, call *goAgent.ToolCall) (goAgent.Approval, error) {
+	fmt.Printf("\n[approval] %s wants to run with arguments:\n%s\n", tool.Function.Name, indentJSON(call.Arguments))
+	for {
+		fmt.Print("Run it? [y]es / [n]o / [e]dit > ")
+		answer, err := p.readLine(ctx)
+		if err != nil {
+			return goAgent.Approval{}, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return goAgent.Approval{Approved: true}, nil
+		case "n", "no":
+			fmt.Print("Reason for the model (optional) > ")
+			reason, err := p.readLine(ctx)
+			if err != nil {
+				return goAgent.Approval{}, err
+			}
+			return goAgent.Approval{Reason: reason}, nil
+		case "e", "edit":
+			fmt.Print("New arguments (JSON object) > ")
+			arguments, err := p.readLine(ctx)
+			if err != nil {
+				return goAgent.Approval{}, err
+			}
+			if !json.Valid([]byte(arguments)) {
+				fmt.Println("Not valid JSON, try again.")
+				continue
+			}
+			return goAgent.Approval{Approved: true, Arguments: json.RawMessage(arguments)}, nil
+		}
+	}
+}
+
+func (p promptApprover) readLine(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("input closed")
+	}
+	return strings.TrimSpace(p.scanner.Text()), nil
+}
+
+func indentJSON(data []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	encoded, err := json.MarshalIndent(value, "  ", "  ")
+	if err != nil {
+		return string(data)
+	}
+	return "  " + string(encoded)
+}
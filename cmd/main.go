@@ -2,53 +2,73 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/EdersenC/goAgent"
 	"github.com/EdersenC/goAgent/api/search"
 	"github.com/EdersenC/goAgent/api/tools"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 )
 
-func init() {
-	agentsFolder, err := os.Open("agents.json")
-	if err != nil {
-		fmt.Println(err)
+// mesh holds the CLI's agents and tools and reloads them when their files change.
+var mesh *goAgent.AgentMesh
+
+// agentRuntime serves the agents of mesh to chats, tools and searches.
+var agentRuntime *goAgent.Runtime
+
+// requiredAgents are the agents the CLI cannot run without; configs lacking one are rejected.
+var requiredAgents = []string{"Embedder", "Summarizer", "Planner"}
+
+// loadAgents loads agents.json (or .yaml/.toml) and any search.json override
+// from goAgent.ConfigPath into mesh and sets up the runtime serving them.
+func loadAgents() {
+	agentsPath, ok := goAgent.FindAgentConfig()
+	if !ok {
+		fmt.Println("none of", goAgent.AgentConfigNames, "found in", goAgent.ConfigPath)
 		os.Exit(1)
 	}
-	toolRegistry = goAgent.NewToolRegistry()
-	err = goAgent.LoadAgents(agentsFolder, &agents)
-	if err != nil {
+	var toolFiles []string
+	if path, ok := goAgent.FindConfig("search.json"); ok {
+		toolFiles = append(toolFiles, path)
+	}
+	mesh = goAgent.NewAgentMesh(agentsPath, toolFiles...)
+	mesh.Prepare = prepareAgents
+	mesh.RegisterTools(tools.SearchTool)
+	if err := mesh.Reload(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	agentRuntime = goAgent.NewRuntime(mesh)
+	agentRuntime.SummaryPorts = []string{"11436"} // a second Ollama summarizing in parallel
+}
 
-	// Initialize the embedding agent
-	embeddingAgent := agents["Embedder"]
-	if embeddingAgent == nil {
-		fmt.Println("Embedding agent not found")
-		os.Exit(1)
-	}
-	goAgent.EmbeddingAgent = embeddingAgent
-	// Initialize the summary agent
-	summaryAgent := agents["Summarizer"]
-	if summaryAgent == nil {
-		fmt.Println("Summary agent not found")
-		os.Exit(1)
+// prepareAgents checks a freshly loaded config before the mesh publishes it.
+func prepareAgents(agents map[string]*goAgent.Agent) error {
+	for _, name := range requiredAgents {
+		if agents[name] == nil {
+			return fmt.Errorf("%s agent not found", name)
+		}
 	}
-	goAgent.SummaryAgent = summaryAgent
-	// Initialize the planner agent
-	plannerAgent := agents["Planner"]
-	if plannerAgent == nil {
-		fmt.Println("Planner agent not found")
-		os.Exit(1)
+	// Check the config against what the servers report; unreachable providers keep their settings.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := goAgent.DiscoverAgents(ctx, agents); err != nil {
+		fmt.Fprintln(os.Stderr, "Model discovery failed:", err)
 	}
-	goAgent.PlannerAgent = plannerAgent
+	return nil
 }
 
-var agents = map[string]*goAgent.Agent{}
-var toolRegistry *goAgent.ToolRegistry
+// newPlanner returns the planner of snapshot with the CLI's system prompt and
+// tools. It is a copy, so the snapshot itself is never modified.
+func newPlanner(snapshot *goAgent.MeshSnapshot) *goAgent.Agent {
+	planner := snapshot.Agents["Planner"].Clone()
+	planner.SystemPrompt = systemPrompt
+	planner.Tools = snapshot.Registry()
+	return planner
+}
 
 var systemPrompt = `
 # 🤖 Your Large‑Language‑Model (LLM) Study Partner
@@ -164,13 +184,21 @@ Ready to dive in and ace that next challenge? 🚀
 `
 
 func chatLoop() {
-	goAgent.PlannerAgent.SystemPrompt = systemPrompt
-	toolRegistry.RegisterTools(tools.SearchTool) // Make sure `tool` is defined
-	goAgent.PlannerAgent.Tools = toolRegistry
-	chat := goAgent.NewChat(goAgent.PlannerAgent, toolRegistry)
-	chat.AddMessage("system", goAgent.PlannerAgent.SystemPrompt)
+	snapshot := mesh.Snapshot()
+	planner := newPlanner(snapshot)
+	chat := goAgent.NewChat(planner, planner.Tools)
+	chat.Runtime = agentRuntime
+	chat.AddMessage("system", planner.SystemPrompt)
+
+	// Edits to the config files are picked up between turns; a turn in
+	// progress finishes with the agents it started with.
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go mesh.Watch(watchCtx, 2*time.Second)
 
 	scanner := bufio.NewScanner(os.Stdin)
+	// Tools with the "ask" policy are confirmed on the terminal before they run.
+	chat.Approver = promptApprover{scanner: scanner}
 	fmt.Println("Interactive chat started. Type 'exit' to quit.")
 	totalTime := time.Now()
 
@@ -187,23 +215,54 @@ func chatLoop() {
 		if input == "" {
 			continue
 		}
+		if latest := mesh.Snapshot(); latest != snapshot {
+			snapshot = latest
+			chat.Agent = newPlanner(snapshot)
+			chat.ToolRegistry = chat.Agent.Tools
+		}
 
-		response, err := chat.SendUserMessage(input, false)
+		// Ctrl-C cancels the in-flight request instead of quitting the session.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		_, err := chat.RunUserMessage(ctx, input, &goAgent.RunOptions{OnEvent: printStream()})
+		stop()
 		if err != nil {
 			fmt.Println("Error:", err)
 			continue
 		}
-		response.PrintThoughts()
-		response.PrintContent()
 		fmt.Println("\n\nTotal duration:", time.Since(loopTime))
 	}
 
 	fmt.Println("\nChat session ended. Total duration:", time.Since(totalTime))
 }
 
+// printStream prints thinking and content tokens as they arrive,
+// with a header each time the stream switches between the two.
+func printStream() goAgent.StreamHandler {
+	var current goAgent.StreamEventType
+	return func(event *goAgent.StreamEvent) {
+		switch event.Type {
+		case goAgent.StreamThinking, goAgent.StreamContent:
+			if event.Type != current {
+				current = event.Type
+				if current == goAgent.StreamThinking {
+					fmt.Println("=====================\nThoughts:")
+				} else {
+					fmt.Println("\n=====================\nContents:")
+				}
+			}
+			fmt.Print(event.Delta)
+		case goAgent.StreamToolCall:
+			fmt.Printf("\n[tool call] %s %s\n", event.ToolCall.Name, event.ToolCall.Arguments)
+		case goAgent.StreamDone:
+			current = "" // print the header again for the next model call
+		}
+	}
+}
+
 func Search(query string) {
 	trace := search.NewTrace("summarize this ", query)
-	trace.Chat = goAgent.NewChat(goAgent.SummaryAgent, goAgent.NewToolRegistry())
+	trace.Runtime = agentRuntime
+	trace.Chat = goAgent.NewChat(agentRuntime.SummaryAgent(), goAgent.NewToolRegistry())
 	err := search.RunQuery(
 		tools.DuckDuckGo{},
 		query,
@@ -220,6 +279,12 @@ func Search(query string) {
 }
 
 func main() {
+	if len(os.Args) > 2 && os.Args[1] == "mcp" && os.Args[2] == "serve" {
+		serveMCP()
+		return
+	}
+
+	loadAgents()
 	tokens := goAgent.Tokenize(systemPrompt)
 	fmt.Printf("System prompt token count: %d tokens\n", tokens)
 	chatLoop()
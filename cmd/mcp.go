@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/EdersenC/goAgent"
+	"github.com/EdersenC/goAgent/api/mcp"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// serveMCP publishes the CLI's tools to MCP hosts over stdin and stdout.
+// Everything else the program prints is sent to stderr while serving, so it
+// cannot corrupt the protocol stream.
+func serveMCP() {
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+
+	loadAgents()
+	registry := mesh.Snapshot().Registry()
+
+	server := mcp.NewServer(registry, mcp.Implementation{Name: "goagent", Version: "0.1.0"})
+	server.NewChat = func() *goAgent.Chat {
+		// Each call runs on the planner of the latest config.
+		chat := goAgent.NewChat(agentRuntime.PlannerAgent().Clone(), goAgent.NewToolRegistry())
+		chat.Runtime = agentRuntime
+		return chat
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go mesh.Watch(ctx, 2*time.Second)
+	fmt.Println("Serving", len(registry.GetTools()), "tools over MCP stdio")
+	err := server.ServeStdio(ctx, os.Stdin, protocolOut)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("MCP server stopped:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,190 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestChatsShareAgent runs chats on one agent at the same time, with a tool
+// whose handler overruns its timeout and keeps reading the chat while the
+// chat goes on. Run with -race.
+func TestChatsShareAgent(t *testing.T) {
+	fake := newFakeOllama(t, func(request map[string]interface{}) map[string]interface{} {
+		messages := request["messages"].([]interface{})
+		last := messages[len(messages)-1].(map[string]interface{})
+		if last["role"] == "tool" {
+			return map[string]interface{}{"role": "assistant", "content": "done"}
+		}
+		reply := callTool("slow", map[string]interface{}{})
+		reply["tool_calls"] = append(reply["tool_calls"].([]interface{}), callTool("echo", map[string]interface{}{})["tool_calls"].([]interface{})...)
+		return reply
+	})
+
+	var handlers sync.WaitGroup
+	readChat := func(inv *ToolInvocation) int {
+		n := 0
+		for _, message := range inv.Chat.Messages {
+			n += len(message.Content)
+			for _, call := range message.ToolCalls {
+				n += len(call.Result)
+			}
+		}
+		return n
+	}
+	slow := NewTool("function", "slow", "overruns its timeout", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		defer handlers.Done()
+		<-inv.Context.Done()
+		time.Sleep(20 * time.Millisecond) // the chat has moved on by now
+		return map[string]interface{}{"read": readChat(inv)}, nil
+	})
+	slow.Timeout = Duration(5 * time.Millisecond)
+	echo := NewTool("function", "echo", "reads the chat", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		return map[string]interface{}{"read": readChat(inv)}, nil
+	})
+	agent := fake.agent(slow, echo)
+
+	const chats = 8
+	var wg sync.WaitGroup
+	for i := 0; i < chats; i++ {
+		handlers.Add(1)
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			chat := NewChat(agent, NewToolRegistry())
+			result, err := chat.RunUserMessage(context.Background(), fmt.Sprint("question ", i), nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if result.Response.Message.Content != "done" {
+				t.Errorf("chat %d ended with %q", i, result.Response.Message.Content)
+			}
+			chat.AddMessage("user", "a message added while the slow handler still runs")
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_ = agent.Clone()
+			_ = agent.GetTools().GetTools()
+			agent.Tools.SetPolicy(fmt.Sprint("unused ", i), ToolDeny)
+			if _, err := json.Marshal(agent); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	handlers.Wait()
+	if agent.Tools.Len() != 2 {
+		t.Fatalf("agent has %d tools, want 2", agent.Tools.Len())
+	}
+}
+
+func TestToolRegistryCopyOnWrite(t *testing.T) {
+	registry := NewToolRegistry(NewTool("function", "a", "", nil))
+	before := registry.GetToolMap()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			registry.RegisterTool(NewTool("function", fmt.Sprint("tool ", i), "", nil))
+			registry.SetPolicy(fmt.Sprint("tool ", i), ToolAsk)
+		}(i)
+		go func() {
+			defer wg.Done()
+			for name, tool := range registry.GetToolMap() {
+				_, _ = name, tool.Function.Name
+			}
+			_ = registry.Clone()
+			_ = registry.GetToolsByName("a", "tool 1")
+			_, _ = json.Marshal(registry)
+		}()
+	}
+	wg.Wait()
+
+	if len(before) != 1 {
+		t.Fatalf("a map returned by GetToolMap changed to %d tools", len(before))
+	}
+	if registry.Len() != 9 {
+		t.Fatalf("registry has %d tools, want 9", registry.Len())
+	}
+}
+
+func TestToolRegistryKeepsPolicies(t *testing.T) {
+	registry := NewToolRegistry(NewTool("function", "a", "", nil), NewTool("function", "b", "", nil))
+	registry.SetPolicy("a", ToolDeny)
+
+	if policy, _ := registry.GetToolsByName("a").Policy("a"); policy != ToolDeny {
+		t.Errorf("GetToolsByName dropped the policy, got %q", policy)
+	}
+	if policy, _ := registry.Clone().Policy("a"); policy != ToolDeny {
+		t.Errorf("Clone dropped the policy, got %q", policy)
+	}
+
+	other := NewToolRegistry(NewTool("function", "a", "", nil))
+	other.SetPolicy("a", ToolAsk)
+	previous := registry.Swap(other)
+	if policy, _ := registry.Policy("a"); policy != ToolAsk {
+		t.Errorf("Swap kept the old policy, got %q", policy)
+	}
+	if policy, _ := previous.Policy("a"); policy != ToolDeny || previous.Len() != 2 {
+		t.Errorf("Swap lost the previous tools or policy: %d tools, %q", previous.Len(), policy)
+	}
+
+	cleared := registry.Clear()
+	if policy, _ := cleared.Policy("a"); policy != ToolAsk || cleared.Len() != 1 || registry.Len() != 0 {
+		t.Errorf("Clear returned %d tools and %q", cleared.Len(), policy)
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	tool := NewTool("function", "search", "", nil)
+	tool.Function.Parameters = *NewToolParameters("object")
+	tool.Function.Parameters.SetProperty("query", NewStringProperty("what to look for").WithLength(1, 100), true)
+	agent := &Agent{Name: "a", Model: Model{Capabilities: []string{"tools"}}, Provider: &Provider{Port: "1"}, Tools: NewToolRegistry(tool)}
+
+	clone := agent.Clone()
+	cloned, _ := clone.Tools.GetTool("search")
+	cloned.Function.Parameters.Properties["query"].Description = "changed"
+	*cloned.Function.Parameters.Properties["query"].MaxLength = 5
+	clone.Tools.RegisterTool(NewTool("function", "extra", "", nil))
+	clone.Model.Capabilities[0] = "changed"
+	clone.Provider.Port = "2"
+
+	query := tool.Function.Parameters.Properties["query"]
+	if query.Description != "what to look for" || *query.MaxLength != 100 {
+		t.Error("clone shares tool parameters")
+	}
+	if agent.Tools.Len() != 1 || agent.Model.Capabilities[0] != "tools" || agent.Provider.Port != "1" {
+		t.Error("clone shares agent state")
+	}
+}
+
+func TestRegisterOnAgentWithoutTools(t *testing.T) {
+	agent := &Agent{Name: "Empty"}
+	agent.GetTools().RegisterTool(NewTool("function", "echo", "echoes", nil))
+	if _, ok := agent.GetTools().GetTool("echo"); !ok {
+		t.Fatal("tool registered through GetTools was lost")
+	}
+
+	shared := &Agent{Name: "Shared"}
+	registries := make([]*ToolRegistry, 8)
+	var wg sync.WaitGroup
+	for i := range registries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registries[i] = shared.GetTools()
+		}()
+	}
+	wg.Wait()
+	for _, registry := range registries {
+		if registry != registries[0] {
+			t.Fatal("concurrent calls to GetTools created different registries")
+		}
+	}
+}
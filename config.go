@@ -0,0 +1,59 @@
+package goAgent
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ConfigEnv names an environment variable holding a directory that is searched
+// for configuration files before the working directory.
+const ConfigEnv = "GOAGENT_CONFIG_DIR"
+
+// ConfigPath lists the directories searched, in order, for configuration files
+// such as agents.json and tool definitions. Files found here override the
+// defaults embedded in the packages that ship them.
+var ConfigPath = defaultConfigPath()
+
+func defaultConfigPath() []string {
+	var dirs []string
+	if dir := os.Getenv(ConfigEnv); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, ".")
+}
+
+// FindConfig returns the path of name in the first directory of ConfigPath that has it.
+func FindConfig(name string) (string, bool) {
+	for _, dir := range ConfigPath {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ReadConfig reads name from ConfigPath, falling back to defaults, which may
+// be nil. It also returns where the file was read from, for error messages.
+func ReadConfig(name string, defaults fs.FS) ([]byte, string, error) {
+	if path, ok := FindConfig(name); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, path, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return data, path, nil
+	}
+	if defaults != nil {
+		data, err := fs.ReadFile(defaults, name)
+		if err == nil {
+			return data, "embedded " + name, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, name, fmt.Errorf("failed to read embedded %s: %w", name, err)
+		}
+	}
+	return nil, name, fmt.Errorf("config file %s not found in %v: %w", name, ConfigPath, fs.ErrNotExist)
+}
@@ -0,0 +1,98 @@
+package goAgent
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useConfigPath searches only dirs for configuration files for the rest of the test.
+func useConfigPath(t *testing.T, dirs ...string) {
+	t.Helper()
+	previous := ConfigPath
+	ConfigPath = dirs
+	t.Cleanup(func() { ConfigPath = previous })
+}
+
+func writeConfig(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	useConfigPath(t, first, second)
+	writeConfig(t, second, "both.json", "second")
+	writeConfig(t, first, "both.json", "first")
+	writeConfig(t, second, "override.json", "file")
+	if err := os.Mkdir(filepath.Join(first, "dir.json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defaults := fstest.MapFS{
+		"override.json": {Data: []byte("embedded")},
+		"embedded.json": {Data: []byte("embedded")},
+	}
+
+	tests := []struct {
+		name   string
+		data   string
+		source string
+	}{
+		{name: "both.json", data: "first", source: filepath.Join(first, "both.json")},
+		{name: "override.json", data: "file", source: filepath.Join(second, "override.json")},
+		{name: "embedded.json", data: "embedded", source: "embedded embedded.json"},
+	}
+	for _, test := range tests {
+		data, source, err := ReadConfig(test.name, defaults)
+		if err != nil || string(data) != test.data || source != test.source {
+			t.Errorf("ReadConfig(%s) = %q from %s, %v; want %q from %s", test.name, data, source, err, test.data, test.source)
+		}
+	}
+
+	for _, name := range []string{"missing.json", "dir.json"} {
+		if _, _, err := ReadConfig(name, defaults); !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), name) {
+			t.Errorf("ReadConfig(%s) = %v, want it reported as not found", name, err)
+		}
+	}
+	if _, _, err := ReadConfig("embedded.json", nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v without defaults, want not found", err)
+	}
+}
+
+func TestInitToolFS(t *testing.T) {
+	useConfigPath(t, t.TempDir())
+	defaults := fstest.MapFS{
+		"echo.json":   {Data: []byte(`{"type":"function","function":{"name":"echo","description":"repeats the message"}}`)},
+		"broken.json": {Data: []byte(`{"type":`)},
+	}
+	handler := func(*ToolInvocation) (map[string]interface{}, error) {
+		return map[string]interface{}{"handled": true}, nil
+	}
+
+	tool := NewTool("function", "old", "", handler)
+	if err := InitToolFS(tool, defaults, "echo.json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if tool.Function.Name != "echo" || tool.Function.Description != "repeats the message" {
+		t.Fatalf("the definition was not loaded: %+v", tool.Function)
+	}
+	if results, err := tool.Call(NewToolCall("", "echo", []byte(`{}`)), nil); err != nil || results["handled"] != true {
+		t.Fatalf("got %v, %v; want the handler kept", results, err)
+	}
+
+	if err := InitToolFS(tool, defaults, "broken.json", handler); err == nil || !strings.Contains(err.Error(), "failed to load tool from embedded broken.json") {
+		t.Fatalf("got %v, want the broken definition reported", err)
+	}
+	if err := InitTool(tool, "echo.json", handler); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want InitTool to ignore embedded definitions", err)
+	}
+	if tool.Function.Name != "echo" {
+		t.Fatal("a failed load changed the tool")
+	}
+}
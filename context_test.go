@@ -0,0 +1,99 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendMessageContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body) // the server only notices the client leaving once the body is read
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	agent := &Agent{Name: "Slow", Model: Model{Name: "fake", ContextWindow: 4096}, Provider: &Provider{Type: BackendOllama, BaseUrl: server.URL}, Retry: &RetryPolicy{MaxAttempts: 1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	chat := NewChat(agent, nil)
+	started := time.Now()
+	for _, stream := range []bool{false, true} {
+		if _, err := chat.SendMessageContext(ctx, "user", "hi", stream); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("stream %t: got %v, want the deadline", stream, err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("the cancelled request took %s", elapsed)
+	}
+	if chat.Context().Err() != nil {
+		t.Fatal("the chat kept the context of a finished request")
+	}
+}
+
+func TestToolGetsRequestContext(t *testing.T) {
+	type key struct{}
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		return callTool("probe", map[string]interface{}{})
+	})
+	var got interface{}
+	probe := NewTool("function", "probe", "reads the request context", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		got = inv.Context.Value(key{})
+		return map[string]interface{}{}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "request")
+	if _, err := NewChat(fake.agent(probe), nil).SendMessageContext(ctx, "user", "hi", false); err != nil {
+		t.Fatal(err)
+	}
+	if got != "request" {
+		t.Fatalf("the tool saw %v, want the request context", got)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := probe.CallContext(cancelled, NewToolCall("", "probe", []byte(`{}`)), nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want a cancelled call refused", err)
+	}
+}
+
+func TestEmbedContextKeepsEmbeddedChunks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n > 1 {
+			_, _ = io.Copy(io.Discard, r.Body)
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float64{1, 2}})
+	}))
+	defer server.Close()
+	agent := &Agent{Name: "Embedder", Model: Model{Name: "embed", ContextWindow: 12}, Provider: &Provider{Type: BackendOllama, BaseUrl: server.URL}, Retry: &RetryPolicy{MaxAttempts: 1}}
+
+	content := strings.Repeat("one two three four five\n", 4)
+	embedded, err := agent.EmbedContext(ctx, content)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the cancellation", err)
+	}
+	if len(embedded) != 1 || embedded[0].Content != "one two three four five\n" {
+		t.Fatalf("got %d chunks, want the one embedded before the cancellation", len(embedded))
+	}
+	if requests != 2 {
+		t.Fatalf("the server got %d requests, want no more after the cancellation", requests)
+	}
+}
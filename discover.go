@@ -0,0 +1,156 @@
+package goAgent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// DiscoveryWarnings receives the warnings Discover prints when an agent's
+// configuration disagrees with its server.
+var DiscoveryWarnings io.Writer = os.Stderr
+
+var modelInfoCache = struct {
+	sync.Mutex
+	entries map[string]*ModelInfo
+}{entries: map[string]*ModelInfo{}}
+
+// ModelInfo returns what the agent's provider reports about its model.
+// Results are cached per provider and model for the life of the process;
+// see ClearModelInfoCache. Discovery is best effort and is not retried.
+// The result is a copy the caller may change.
+func (a *Agent) ModelInfo(ctx context.Context) (*ModelInfo, error) {
+	if a.Provider == nil {
+		return nil, fmt.Errorf("agent %s has no provider", a.Name)
+	}
+	backend, err := a.Provider.Backend()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s|%s|%s", a.Provider.Type, a.Provider.endpointUrl("", ""), a.Model.Name)
+
+	modelInfoCache.Lock()
+	info, ok := modelInfoCache.entries[key]
+	modelInfoCache.Unlock()
+	if ok {
+		return info.clone(), nil
+	}
+
+	info, err = backend.ShowModel(ctx, a.Model.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering model %s: %w", a.Model.Name, err)
+	}
+	modelInfoCache.Lock()
+	modelInfoCache.entries[key] = info.clone()
+	modelInfoCache.Unlock()
+	return info, nil
+}
+
+func (m *ModelInfo) clone() *ModelInfo {
+	c := *m
+	c.Capabilities = slices.Clone(m.Capabilities)
+	return &c
+}
+
+// ClearModelInfoCache forgets all discovered model information,
+// e.g. after models were pulled or replaced on the server.
+func ClearModelInfoCache() {
+	modelInfoCache.Lock()
+	defer modelInfoCache.Unlock()
+	modelInfoCache.entries = map[string]*ModelInfo{}
+}
+
+// Discover queries the provider for the agent's model and reconciles the
+// hand-written Model settings with it, writing a warning to DiscoveryWarnings
+// for every value the server disagrees with:
+//   - ContextWindow and EmbeddingLength are filled in when unset. A context
+//     window larger than the server's (or than Options.NumCtx) is clamped.
+//   - Capabilities are replaced by the server's list when it reports one.
+//   - Reasoning is turned off when the server says the model cannot think.
+//   - Registered tools are flagged when the model does not support tools.
+func (a *Agent) Discover(ctx context.Context) error {
+	info, err := a.ModelInfo(ctx)
+	if err != nil {
+		return err
+	}
+	model := &a.Model
+
+	if info.ContextLength > 0 {
+		limit := info.ContextLength
+		if model.Options != nil && model.Options.NumCtx != nil && *model.Options.NumCtx < limit {
+			limit = *model.Options.NumCtx
+		}
+		switch {
+		case model.ContextWindow == 0:
+			model.ContextWindow = limit
+		case model.ContextWindow > limit:
+			a.warn("contextWindow %d exceeds the model's limit of %d tokens, using %d", model.ContextWindow, limit, limit)
+			model.ContextWindow = limit
+		case model.ContextWindow != info.ContextLength && limit == info.ContextLength:
+			a.warn("contextWindow %d differs from the server's context length %d", model.ContextWindow, info.ContextLength)
+		}
+	}
+
+	if info.EmbeddingLength > 0 {
+		if model.EmbeddingLength != 0 && model.EmbeddingLength != info.EmbeddingLength {
+			a.warn("embeddingLength %d differs from the server's %d, using %d", model.EmbeddingLength, info.EmbeddingLength, info.EmbeddingLength)
+		}
+		model.EmbeddingLength = info.EmbeddingLength
+	}
+
+	if info.Capabilities == nil {
+		return nil // nothing more to compare against
+	}
+	if model.Capabilities != nil && !sameCapabilities(model.Capabilities, info.Capabilities) {
+		a.warn("capabilities %v differ from the server's %v", model.Capabilities, info.Capabilities)
+	}
+	model.Capabilities = append([]string(nil), info.Capabilities...)
+
+	if model.Reasoning && !info.Supports(CapabilityThinking) {
+		a.warn("reasoning is enabled but the model does not support thinking, disabling it")
+		model.Reasoning = false
+	}
+	if a.Tools != nil && len(a.Tools.GetTools()) > 0 && !info.Supports(CapabilityTools) {
+		a.warn("%d tools are registered but the model does not support tool calls", len(a.Tools.GetTools()))
+	}
+	return nil
+}
+
+// Supports reports whether the model is known to have the given capability.
+func (m Model) Supports(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+// DiscoverAgents runs Discover for every agent. Agents whose provider cannot be
+// reached keep their configured values; their errors are returned joined.
+func DiscoverAgents(ctx context.Context, agents map[string]*Agent) error {
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := agents[name].Discover(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Agent) warn(format string, args ...interface{}) {
+	fmt.Fprintf(DiscoveryWarnings, "Warning: agent %s (%s): %s\n", a.Name, a.Model.Name, fmt.Sprintf(format, args...))
+}
+
+func sameCapabilities(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,201 @@
+package goAgent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeModelServer serves Ollama's /api/tags and /api/show for one model and
+// counts the /api/show requests.
+type fakeModelServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	shows int
+}
+
+func newFakeModelServer(t *testing.T, name string, contextLength int, capabilities ...string) *fakeModelServer {
+	t.Helper()
+	fake := &fakeModelServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ollamaTagsEndpoint:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"models": []interface{}{map[string]interface{}{"name": name + ":latest", "size": 42}},
+			})
+		case ollamaShowEndpoint:
+			fake.mu.Lock()
+			fake.shows++
+			fake.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"capabilities": capabilities,
+				"model_info": map[string]interface{}{
+					"llama.context_length":   contextLength,
+					"llama.embedding_length": 768,
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeModelServer) agent(model Model) *Agent {
+	return &Agent{Name: "Discovered", Model: model, Provider: &Provider{Type: "ollama", BaseUrl: f.URL}}
+}
+
+// captureWarnings sends discovery warnings to the returned buffer for the rest of the test.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	previous := DiscoveryWarnings
+	DiscoveryWarnings = &buffer
+	t.Cleanup(func() { DiscoveryWarnings = previous })
+	return &buffer
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        Model
+		capabilities []string
+		tools        bool
+		want         Model
+		warning      string
+	}{
+		{
+			name:         "fills unset values",
+			model:        Model{Name: "llama"},
+			capabilities: []string{"completion", "tools"},
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion", "tools"}},
+		},
+		{
+			name:         "clamps the context window",
+			model:        Model{Name: "llama", ContextWindow: 32768},
+			capabilities: []string{"completion"},
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion"}},
+			warning:      "exceeds the model's limit of 8192 tokens",
+		},
+		{
+			name:         "clamps to num_ctx",
+			model:        Model{Name: "llama", Options: &ModelOptions{NumCtx: Ptr(2048)}},
+			capabilities: []string{"completion"},
+			want:         Model{Name: "llama", ContextWindow: 2048, EmbeddingLength: 768, Capabilities: []string{"completion"}, Options: &ModelOptions{NumCtx: Ptr(2048)}},
+		},
+		{
+			name:         "replaces capabilities",
+			model:        Model{Name: "llama", ContextWindow: 8192, Capabilities: []string{"vision"}},
+			capabilities: []string{"completion"},
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion"}},
+			warning:      "capabilities [vision] differ",
+		},
+		{
+			name:         "turns reasoning off",
+			model:        Model{Name: "llama", ContextWindow: 8192, Reasoning: true},
+			capabilities: []string{"completion"},
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion"}},
+			warning:      "does not support thinking",
+		},
+		{
+			name:         "keeps reasoning",
+			model:        Model{Name: "llama", ContextWindow: 8192, Reasoning: true},
+			capabilities: []string{"completion", "thinking"},
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion", "thinking"}, Reasoning: true},
+		},
+		{
+			name:         "flags tools",
+			model:        Model{Name: "llama", ContextWindow: 8192},
+			capabilities: []string{"completion"},
+			tools:        true,
+			want:         Model{Name: "llama", ContextWindow: 8192, EmbeddingLength: 768, Capabilities: []string{"completion"}},
+			warning:      "1 tools are registered",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ClearModelInfoCache()
+			warnings := captureWarnings(t)
+			agent := newFakeModelServer(t, "llama", 8192, test.capabilities...).agent(test.model)
+			if test.tools {
+				agent.RegisterTools(NewTool("function", "noop", "does nothing", nil))
+			}
+			if err := agent.Discover(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(agent.Model)
+			want, _ := json.Marshal(test.want)
+			if string(got) != string(want) {
+				t.Fatalf("got model %s, want %s", got, want)
+			}
+			if test.warning == "" && warnings.Len() > 0 {
+				t.Fatalf("unexpected warning %q", warnings)
+			}
+			if !strings.Contains(warnings.String(), test.warning) {
+				t.Fatalf("got warnings %q, want %q", warnings, test.warning)
+			}
+		})
+	}
+}
+
+func TestModelInfoCache(t *testing.T) {
+	ClearModelInfoCache()
+	fake := newFakeModelServer(t, "llama", 8192, "completion")
+	agent := fake.agent(Model{Name: "llama"})
+
+	info, err := agent.ModelInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "llama:latest" || info.Size != 42 || info.ContextLength != 8192 {
+		t.Fatalf("unexpected model info %+v", info)
+	}
+	info.ContextLength = 1
+	info.Capabilities[0] = "changed"
+
+	again, err := agent.ModelInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ContextLength != 8192 || again.Capabilities[0] != "completion" {
+		t.Fatalf("changing a result changed the cache: %+v", again)
+	}
+	if fake.shows != 1 {
+		t.Fatalf("the server was asked %d times, want once", fake.shows)
+	}
+
+	ClearModelInfoCache()
+	if _, err = agent.ModelInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.shows != 2 {
+		t.Fatal("ClearModelInfoCache kept the cached model")
+	}
+}
+
+func TestDiscoverAgents(t *testing.T) {
+	ClearModelInfoCache()
+	warnings := captureWarnings(t)
+	fake := newFakeModelServer(t, "llama", 8192, "completion")
+	agents := map[string]*Agent{
+		"Known":   fake.agent(Model{Name: "llama"}),
+		"Missing": fake.agent(Model{Name: "mistral", ContextWindow: 4096}),
+	}
+
+	err := DiscoverAgents(context.Background(), agents)
+	if err == nil || !strings.Contains(err.Error(), "agent Missing") || !strings.Contains(err.Error(), "mistral") {
+		t.Fatalf("got %v, want the missing model reported", err)
+	}
+	if agents["Known"].Model.ContextWindow != 8192 || agents["Missing"].Model.ContextWindow != 4096 {
+		t.Fatalf("unexpected context windows %d and %d", agents["Known"].Model.ContextWindow, agents["Missing"].Model.ContextWindow)
+	}
+	if warnings.Len() > 0 {
+		t.Fatalf("errors were also written as warnings: %q", warnings)
+	}
+}
@@ -0,0 +1,133 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps how much of a failed response body is kept on a ProviderError.
+const maxErrorBody = 64 << 10
+
+// ProviderError describes a model provider call that failed, either because the
+// server answered with an error status or because no response arrived at all.
+// Use errors.As to inspect it.
+type ProviderError struct {
+	URL        string
+	StatusCode int           // 0 when the request never got a response
+	Status     string        // e.g. "404 Not Found"
+	Body       string        // raw (truncated) response body
+	Message    string        // error message extracted from the body, if any
+	Retryable  bool          // whether repeating the request may succeed
+	RetryAfter time.Duration // server requested delay before retrying, if any
+	Err        error         // underlying transport error, if any
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("error sending request to %s: %v", e.URL, e.Err)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("provider returned %s for %s: %s", e.Status, e.URL, e.Message)
+	}
+	return fmt.Sprintf("provider returned %s for %s", e.Status, e.URL)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a ProviderError worth retrying.
+func IsRetryable(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && providerErr.Retryable
+}
+
+// retryableStatus reports whether a response status signals a transient failure.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic "overloaded"
+		return true
+	}
+	return false
+}
+
+// newTransportError wraps a failed client.Do call. Transport failures are
+// retryable unless they were caused by the caller's context ending.
+func newTransportError(req *http.Request, err error) *ProviderError {
+	retryable := req.Context().Err() == nil &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+	return &ProviderError{
+		URL:       req.URL.String(),
+		Retryable: retryable,
+		Err:       err,
+	}
+}
+
+// checkResponse turns an error status into a ProviderError, consuming and closing the body.
+// Successful responses are returned untouched.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &ProviderError{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		Message:    errorMessage(body),
+		Retryable:  retryableStatus(resp.StatusCode),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// errorMessage extracts the error text from the error bodies used by
+// Ollama ({"error": "..."}) and OpenAI/Anthropic ({"error": {"message": "..."}}).
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed.Error) == 0 {
+		return strings.TrimSpace(string(body))
+	}
+	var message string
+	if err := json.Unmarshal(parsed.Error, &message); err == nil {
+		return message
+	}
+	var nested struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(parsed.Error, &nested); err == nil && nested.Message != "" {
+		return nested.Message
+	}
+	return string(parsed.Error)
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
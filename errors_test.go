@@ -0,0 +1,76 @@
+package goAgent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		message    string
+		retryable  bool
+		wait       time.Duration
+	}{
+		{name: "openai", status: http.StatusBadRequest, body: `{"error":{"message":"bad model","type":"invalid_request_error"}}`, message: "bad model"},
+		{name: "ollama", status: http.StatusNotFound, body: `{"error":"model not found"}`, message: "model not found"},
+		{name: "plain text", status: http.StatusInternalServerError, body: "boom\n", message: "boom", retryable: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, retryAfter: "3", body: `{"error":{"message":"slow down"}}`, message: "slow down", retryable: true, wait: 3 * time.Second},
+		{name: "bad retry after", status: http.StatusServiceUnavailable, retryAfter: "Wed, 21 Oct 2015 07:28:00 GMT", retryable: true},
+		{name: "overloaded", status: 529, body: `{"error":{"type":"overloaded_error","message":"Overloaded"}}`, message: "Overloaded", retryable: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error":{"code":401}}`, message: `{"code":401}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			backend := newOpenAIBackend(&Provider{Type: BackendOpenAI, BaseUrl: server.URL})
+			for _, stream := range []bool{false, true} {
+				_, err := backend.Chat(context.Background(), &ChatRequest{Model: Model{Name: "m"}, Stream: stream})
+				var providerErr *ProviderError
+				if !errors.As(err, &providerErr) {
+					t.Fatalf("stream %t: got %v, want a ProviderError", stream, err)
+				}
+				if providerErr.StatusCode != test.status || providerErr.Body != test.body || providerErr.URL != server.URL+openAIChatEndpoint {
+					t.Fatalf("stream %t: unexpected error %+v", stream, providerErr)
+				}
+				if providerErr.Message != test.message || providerErr.Retryable != test.retryable || providerErr.RetryAfter != test.wait {
+					t.Fatalf("stream %t: got message %q, retryable %t and retry after %s", stream, providerErr.Message, providerErr.Retryable, providerErr.RetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	backend := newOpenAIBackend(&Provider{Type: BackendOpenAI, BaseUrl: server.URL})
+
+	_, err := backend.Chat(context.Background(), &ChatRequest{Model: Model{Name: "m"}})
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != 0 || !providerErr.Retryable || providerErr.Err == nil {
+		t.Fatalf("got %v, want a retryable transport error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = backend.Chat(ctx, &ChatRequest{Model: Model{Name: "m"}})
+	if IsRetryable(err) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the cancellation, not retryable", err)
+	}
+}
@@ -4,7 +4,11 @@ go 1.23.0
 
 toolchain go1.23.6
 
-require github.com/PuerkitoBio/goquery v1.10.3
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/PuerkitoBio/goquery v1.10.3
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
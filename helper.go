@@ -2,9 +2,11 @@ package goAgent
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"regexp"
@@ -25,24 +27,35 @@ func DecodeChatResponse(body io.Reader) (*ChatResponse, error) {
 		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
 	}
 
-	response.Message.Raw = response.Message.Content
-	response.Message.ToolCalls = append(response.Message.ToolCalls, response.ExtractToolCalls()...)
-	response.Message.Thinking = response.ExtractThinking()
-	response.Message.Content = response.ExtractFinalContent()
+	response.normalizeMessage()
 	return &response, nil
 }
 
+// normalizeMessage fills in tool calls and thinking for the message.
+// Structured fields returned by the server win; the <tool_call> and <think>
+// tags in the content are only parsed when those fields are empty.
+func (cr *ChatResponse) normalizeMessage() {
+	cr.Message.Raw = cr.Message.Content
+	if len(cr.Message.ToolCalls) == 0 {
+		cr.Message.ToolCalls = cr.ExtractToolCalls()
+	}
+	if cr.Message.Thinking == "" {
+		cr.Message.Thinking = cr.ExtractThinking()
+	}
+	cr.Message.Content = cr.ExtractFinalContent()
+}
+
 // Returns parsed ToolCalls from the message content (no side effects).
-func (cr *ChatResponse) ExtractToolCalls() []map[string]interface{} {
+func (cr *ChatResponse) ExtractToolCalls() []*ToolCall {
 	re := regexp.MustCompile(`(?s)<tool_call>(.*?)</tool_call>`)
 	matches := re.FindAllStringSubmatch(cr.Message.Content, -1)
 
-	var toolCalls []map[string]interface{}
+	var toolCalls []*ToolCall
 	for _, match := range matches {
 		toolCallStr := strings.TrimSpace(match[1])
-		var toolCall map[string]interface{}
+		var toolCall ToolCall
 		if err := json.Unmarshal([]byte(toolCallStr), &toolCall); err == nil {
-			toolCalls = append(toolCalls, map[string]interface{}{"function": toolCall})
+			toolCalls = append(toolCalls, &toolCall)
 		} else {
 			fmt.Println("Failed to parse tool_call JSON:", err)
 		}
@@ -109,8 +122,8 @@ func marshalPayload(payload any) ([]byte, error) {
 	return jsonData, nil
 }
 
-func createPostRequest(url string, jsonData []byte) (*http.Request, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+func createPostRequest(ctx context.Context, url string, jsonData []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -118,10 +131,75 @@ func createPostRequest(url string, jsonData []byte) (*http.Request, error) {
 	return req, nil
 }
 
+func createGetRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	return req, nil
+}
+
+// postJSON marshals payload, posts it to url with the given extra headers and returns the response body.
+func postJSON(ctx context.Context, url string, payload any, header http.Header) ([]byte, error) {
+	jsonData, err := marshalPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := createPostRequest(ctx, url, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return doRequest(req)
+}
+
+// getJSON performs a GET request against url with the given extra headers and returns the response body.
+func getJSON(ctx context.Context, url string, header http.Header) ([]byte, error) {
+	req, err := createGetRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return doRequest(req)
+}
+
+// postStream posts payload to url and returns the still open response body for
+// incremental reading. The caller must close it.
+func postStream(ctx context.Context, url string, payload any, header http.Header) (io.ReadCloser, error) {
+	jsonData, err := marshalPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := createPostRequest(ctx, url, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := RuntimeFrom(req.Context()).Client().Do(req)
+	if err != nil {
+		return nil, newTransportError(req, err)
+	}
+	if err = checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// doRequest sends req and returns the response body.
+// Error statuses and transport failures are reported as *ProviderError.
 func doRequest(req *http.Request) ([]byte, error) {
-	resp, err := client.Do(req)
+	resp, err := RuntimeFrom(req.Context()).Client().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		return nil, newTransportError(req, err)
+	}
+	if err = checkResponse(resp); err != nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err = Body.Close()
@@ -163,20 +241,31 @@ func ChunkByTokens(text string, limit int) []string {
 	return chunks
 }
 
-func InitTool(tool *Tool, fileName string, function func(map[string]interface{}, *Chat) (map[string]interface{}, error)) {
-	toolJson, err := os.Open(fileName)
+// InitTool loads the definition of tool from fileName, found in ConfigPath,
+// and attaches function as its handler.
+func InitTool(tool *Tool, fileName string, function ToolHandler) error {
+	return InitToolFS(tool, nil, fileName, function)
+}
+
+// InitToolFS is InitTool with a fallback: when no directory in ConfigPath has
+// fileName it is read from defaults, typically an embed.FS holding the
+// definitions the package ships with.
+func InitToolFS(tool *Tool, defaults fs.FS, fileName string, function ToolHandler) error {
+	data, source, err := ReadConfig(fileName, defaults)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
-	err = LoadTool(toolJson, tool)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	var loaded Tool
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to load tool from %s: %w", source, err)
 	}
 	if function != nil {
-		tool.Function.FunctionCall = function
+		loaded.Function.FunctionCall = function
+	} else {
+		loaded.Function.FunctionCall = tool.Function.FunctionCall
 	}
+	*tool = loaded
+	return nil
 }
 
 func LoadTool(file *os.File, tool *Tool) error {
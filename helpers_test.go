@@ -0,0 +1,63 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeOllama serves Ollama's chat endpoint, answering every request with the
+// message returned by reply. It records the decoded requests. Every reply
+// counts tokens prompt tokens and tokens completion tokens.
+type fakeOllama struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	tokens   int
+}
+
+func newFakeOllama(t *testing.T, reply func(request map[string]interface{}) map[string]interface{}) *fakeOllama {
+	t.Helper()
+	fake := &fakeOllama{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, request)
+		fake.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":             request["model"],
+			"done":              true,
+			"message":           reply(request),
+			"prompt_eval_count": fake.tokens,
+			"eval_count":        fake.tokens,
+		})
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// agent returns an agent talking to the fake server.
+func (f *fakeOllama) agent(tools ...*Tool) *Agent {
+	return &Agent{
+		Name:     "Fake",
+		Model:    Model{Name: "fake", ContextWindow: 4096},
+		Provider: &Provider{Type: "ollama", BaseUrl: f.URL},
+		Tools:    NewToolRegistry(tools...),
+	}
+}
+
+// callTool is a reply calling the named tool with arguments.
+func callTool(name string, arguments map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"role": "assistant",
+		"tool_calls": []interface{}{
+			map[string]interface{}{"function": map[string]interface{}{"name": name, "arguments": arguments}},
+		},
+	}
+}
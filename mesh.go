@@ -0,0 +1,310 @@
+package goAgent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AgentMesh holds the agents and tools of an application and reloads them
+// from their files while it runs. Every successful reload publishes a new
+// MeshSnapshot. Published agents and tools are never modified, so a Chat built
+// from a snapshot keeps running on it while newer snapshots replace it.
+type AgentMesh struct {
+	AgentsFile string   // agents.json, .yaml or .toml; see ParseAgents
+	ToolFiles  []string // tool definitions, a single tool or an array of tools per file
+	// Prepare, if set, is called with the agents of a reload before they are
+	// published, e.g. to run DiscoverAgents. An error rejects the reload.
+	Prepare func(agents map[string]*Agent) error
+	// OnReload, if set, is called by Watch after every reload with the new
+	// snapshot, or with the error that kept the previous one. Without it
+	// reloads are reported on stdout.
+	OnReload func(snapshot *MeshSnapshot, err error)
+
+	mu       sync.Mutex // serializes reloads
+	current  atomic.Pointer[MeshSnapshot]
+	handlers map[string]*Tool
+	stamps   map[string]fileStamp
+	lastErr  error
+}
+
+// MeshSnapshot is one version of a mesh's agents and tools.
+// Treat it as read-only: it is shared by every chat created from it.
+type MeshSnapshot struct {
+	Version  int
+	LoadedAt time.Time
+	Agents   map[string]*Agent
+	Tools    map[string]*Tool
+
+	files []*Tool // definitions read from ToolFiles, before handlers are attached
+}
+
+// fileStamp identifies a version of a watched file.
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// NewAgentMesh returns a mesh that loads its agents from agentsFile and tool
+// definitions from toolFiles. Nothing is read until Reload.
+func NewAgentMesh(agentsFile string, toolFiles ...string) *AgentMesh {
+	return &AgentMesh{AgentsFile: agentsFile, ToolFiles: toolFiles}
+}
+
+// NewStaticMesh returns a mesh publishing copies of agents, without any files to reload.
+func NewStaticMesh(agents map[string]*Agent) *AgentMesh {
+	mesh := &AgentMesh{}
+	mesh.publish(agents, nil)
+	return mesh
+}
+
+// Snapshot returns the current version of the mesh. It is never nil; before
+// the first load it has no agents or tools.
+func (m *AgentMesh) Snapshot() *MeshSnapshot {
+	if snapshot := m.current.Load(); snapshot != nil {
+		return snapshot
+	}
+	return &MeshSnapshot{Agents: map[string]*Agent{}, Tools: map[string]*Tool{}}
+}
+
+// Agent returns the current definition of the agent called name.
+func (m *AgentMesh) Agent(name string) (*Agent, bool) {
+	return m.Snapshot().Agent(name)
+}
+
+// LastError returns the error of the last reload, or nil if it succeeded.
+func (m *AgentMesh) LastError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastErr
+}
+
+// RegisterTools adds tools defined in Go to the mesh. A tool of the same name
+// in ToolFiles replaces the definition but keeps the handler, so descriptions
+// and parameters can be edited while the program runs. The tools are
+// published in a new snapshot right away.
+func (m *AgentMesh) RegisterTools(tools ...*Tool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[string]*Tool)
+	}
+	for _, tool := range tools {
+		if tool != nil && tool.Function.Name != "" {
+			m.handlers[tool.Function.Name] = tool
+		}
+	}
+	current := m.Snapshot()
+	m.publish(current.Agents, current.files)
+}
+
+// Reload reads AgentsFile and ToolFiles and publishes them as a new snapshot.
+// On error the current snapshot stays in place.
+func (m *AgentMesh) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stamps = m.stat() // before reading, so a write during the reload is seen next time
+	err := m.reload()
+	m.lastErr = err
+	return err
+}
+
+func (m *AgentMesh) reload() error {
+	agents := map[string]*Agent{}
+	if m.AgentsFile != "" {
+		loaded, err := LoadAgentsFile(m.AgentsFile)
+		if err != nil {
+			return err
+		}
+		agents = loaded
+	}
+	var fileTools []*Tool
+	for _, path := range m.ToolFiles {
+		loaded, err := readToolFile(path)
+		if err != nil {
+			return err
+		}
+		fileTools = append(fileTools, loaded...)
+	}
+	if m.Prepare != nil {
+		if err := m.Prepare(agents); err != nil {
+			return fmt.Errorf("failed to prepare agents from %s: %w", m.AgentsFile, err)
+		}
+	}
+	m.publish(agents, fileTools)
+	return nil
+}
+
+// publish stores a new snapshot of copies of agents, so the caller's agents
+// stay its own, and of the tools registered in Go, replaced by the definitions
+// read from files. Those get the handler of the registered tool they replace,
+// on a copy, as both may already be published.
+func (m *AgentMesh) publish(agents map[string]*Agent, fileTools []*Tool) {
+	published := make(map[string]*Agent, len(agents))
+	for name, agent := range agents {
+		if agent != nil {
+			agent = agent.Clone() // also gives agents without tools an empty registry
+		}
+		published[name] = agent
+	}
+	tools := make(map[string]*Tool, len(m.handlers)+len(fileTools))
+	for name, tool := range m.handlers {
+		tools[name] = tool
+	}
+	for _, tool := range fileTools {
+		if registered, ok := m.handlers[tool.Function.Name]; ok && tool.Function.FunctionCall == nil {
+			merged := *tool
+			merged.Function.FunctionCall = registered.Function.FunctionCall
+			tool = &merged
+		}
+		tools[tool.Function.Name] = tool
+	}
+
+	version := 1
+	if previous := m.current.Load(); previous != nil {
+		version = previous.Version + 1
+	}
+	m.current.Store(&MeshSnapshot{
+		Version:  version,
+		LoadedAt: time.Now(),
+		Agents:   published,
+		Tools:    tools,
+		files:    fileTools,
+	})
+}
+
+// Watch polls the mesh's files every interval and reloads them when one
+// changes, until ctx is done. Errors keep the previous snapshot and are
+// retried on the next change.
+func (m *AgentMesh) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !m.changed() {
+			continue
+		}
+		err := m.Reload()
+		snapshot := m.Snapshot()
+		switch {
+		case m.OnReload != nil:
+			m.OnReload(snapshot, err)
+		case err != nil:
+			fmt.Printf("Failed to reload agents, keeping version %d: %s\n", snapshot.Version, err)
+		default:
+			fmt.Printf("Reloaded agents (version %d)\n", snapshot.Version)
+		}
+	}
+}
+
+// changed reports whether any watched file differs from the last reload.
+func (m *AgentMesh) changed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.stat()
+	if len(current) != len(m.stamps) {
+		return true
+	}
+	for path, stamp := range current {
+		if m.stamps[path] != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *AgentMesh) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(m.ToolFiles)+1)
+	for _, path := range append([]string{m.AgentsFile}, m.ToolFiles...) {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+		} else {
+			stamps[path] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// readToolFile reads a file holding either one tool, as written by SaveTool,
+// or an array of tools, as written by SaveTools.
+func readToolFile(path string) ([]*Tool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tools: %w", err)
+	}
+	var tools []*Tool
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &tools)
+	} else {
+		var tool Tool
+		err = json.Unmarshal(data, &tool)
+		tools = append(tools, &tool)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tools from %s: %w", path, err)
+	}
+	for i, tool := range tools {
+		if tool == nil || tool.Function.Name == "" {
+			return nil, fmt.Errorf("failed to load tools from %s: tool %d has no name", path, i)
+		}
+	}
+	return tools, nil
+}
+
+// Agent returns the agent called name.
+func (s *MeshSnapshot) Agent(name string) (*Agent, bool) {
+	agent, ok := s.Agents[name]
+	return agent, ok
+}
+
+// Registry returns a new registry holding the named tools of the snapshot,
+// or all of them when no names are given. Unknown names are skipped.
+func (s *MeshSnapshot) Registry(names ...string) *ToolRegistry {
+	registry := NewToolRegistry()
+	if len(names) == 0 {
+		for _, tool := range s.Tools {
+			registry.RegisterTool(tool)
+		}
+		return registry
+	}
+	for _, name := range names {
+		if tool, ok := s.Tools[name]; ok {
+			registry.RegisterTool(tool)
+		}
+	}
+	return registry
+}
+
+// MarshalJSON writes the agents of the current snapshot as {"agents": {...}}.
+func (m *AgentMesh) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Agents map[string]*Agent `json:"agents"`
+	}{m.Snapshot().Agents})
+}
+
+// UnmarshalJSON publishes the agents of {"agents": {...}} as a new snapshot.
+func (m *AgentMesh) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		Agents map[string]*Agent `json:"agents"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publish(decoded.Agents, m.Snapshot().files)
+	return nil
+}
@@ -0,0 +1,56 @@
+package goAgent
+
+import "testing"
+
+func TestStaticMeshCopiesAgents(t *testing.T) {
+	agent := &Agent{Name: "Planner", SystemPrompt: "plan", Provider: &Provider{Type: "ollama"}}
+	agents := map[string]*Agent{"Planner": agent}
+	mesh := NewStaticMesh(agents)
+
+	if agent.Tools != nil {
+		t.Fatal("publishing gave the caller's agent a tool registry")
+	}
+	published, ok := mesh.Agent("Planner")
+	if !ok || published == agent || published.Provider == agent.Provider {
+		t.Fatal("the snapshot shares the caller's agent")
+	}
+	if published.Tools == nil {
+		t.Fatal("published agent has no tool registry")
+	}
+
+	agent.SystemPrompt = "changed"
+	agent.Provider.Type = "openai"
+	agents["Other"] = &Agent{Name: "Other"}
+	if published.SystemPrompt != "plan" || published.Provider.Type != "ollama" {
+		t.Fatalf("changing the caller's agent changed the snapshot: %+v", published)
+	}
+	if _, ok = mesh.Agent("Other"); ok {
+		t.Fatal("adding to the caller's map changed the snapshot")
+	}
+
+	mesh.RegisterTools(NewTool("function", "noop", "does nothing", nil))
+	if republished, _ := mesh.Agent("Planner"); republished.SystemPrompt != "plan" || published.SystemPrompt != "plan" {
+		t.Fatal("republishing changed the agents")
+	}
+}
+
+func TestReloadCopiesPreparedAgents(t *testing.T) {
+	var prepared map[string]*Agent
+	mesh := NewAgentMesh("")
+	mesh.Prepare = func(agents map[string]*Agent) error {
+		agents["Planner"] = &Agent{Name: "Planner"}
+		prepared = agents
+		return nil
+	}
+	if err := mesh.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	published, ok := mesh.Agent("Planner")
+	if !ok || published == prepared["Planner"] {
+		t.Fatal("the snapshot shares the agent given to Prepare")
+	}
+	prepared["Planner"].Name = "changed"
+	if published.Name != "Planner" {
+		t.Fatal("changing a prepared agent changed the snapshot")
+	}
+}
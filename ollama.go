@@ -0,0 +1,286 @@
+package goAgent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ollamaChatEndpoint     = "/api/chat"
+	ollamaGenerateEndpoint = "/api/generate"
+	ollamaEmbedEndpoint    = "/api/embeddings"
+	ollamaTagsEndpoint     = "/api/tags"
+	ollamaShowEndpoint     = "/api/show"
+)
+
+// ollamaBackend speaks the Ollama REST API.
+type ollamaBackend struct {
+	provider *Provider
+}
+
+func newOllamaBackend(provider *Provider) Backend {
+	return &ollamaBackend{provider: provider}
+}
+
+func (o *ollamaBackend) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	payload := map[string]interface{}{
+		"model":      req.Model.Name,
+		"messages":   req.Messages,
+		"stream":     req.Stream,
+		"tools":      toOllamaTools(req.Tools),
+		"keep_alive": req.Model.Options.ollamaKeepAlive(),
+	}
+	if options := req.Model.Options.ollamaOptions(); len(options) > 0 {
+		payload["options"] = options
+	}
+	if req.Format != nil {
+		payload["format"] = req.Format
+	}
+	// Thinking models think unless told not to, so they always get the flag.
+	if req.Model.Reasoning || req.Model.Supports(CapabilityThinking) {
+		payload["think"] = req.Model.Reasoning
+	}
+
+	url := o.provider.endpointUrl(o.provider.ChatEndpoint, ollamaChatEndpoint)
+	if req.Stream {
+		return o.chatStream(ctx, url, payload, req.OnEvent)
+	}
+	body, err := postJSON(ctx, url, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeChatResponse(bytes.NewReader(body))
+}
+
+type ollamaTool struct {
+	Type     string             `json:"type"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  ToolParameters `json:"parameters"`
+}
+
+// toOllamaTools sends each tool as a function with a complete parameter schema.
+// Ollama ignores examples and constraints, so they are left out.
+func toOllamaTools(tools []*Tool) []ollamaTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	converted := make([]ollamaTool, 0, len(tools))
+	for _, tool := range tools {
+		converted = append(converted, ollamaTool{
+			Type: "function",
+			Function: ollamaToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters.Schema(),
+			},
+		})
+	}
+	return converted
+}
+
+// chatStream reads Ollama's NDJSON chat stream, forwarding deltas to handler,
+// and assembles the chunks into a single response.
+func (o *ollamaBackend) chatStream(ctx context.Context, url string, payload map[string]interface{}, handler StreamHandler) (*ChatResponse, error) {
+	body, err := postStream(ctx, url, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	splitter := newTagSplitter(handler)
+	var final ChatResponse
+	var content, thinking strings.Builder
+	var toolCalls []*ToolCall
+	err = readNDJSON(body, func(line []byte) error {
+		var chunk struct {
+			ChatResponse
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if chunk.Message.Thinking != "" {
+			thinking.WriteString(chunk.Message.Thinking)
+			handler.emit(&StreamEvent{Type: StreamThinking, Delta: chunk.Message.Thinking})
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			splitter.feed(chunk.Message.Content)
+		}
+		toolCalls = append(toolCalls, chunk.Message.ToolCalls...)
+		if chunk.Done {
+			final = chunk.ChatResponse
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	splitter.flush()
+
+	if final.Message.Role == "" {
+		final.Message.Role = "assistant"
+	}
+	final.Message.Content = content.String()
+	final.Message.Thinking = thinking.String()
+	final.Message.ToolCalls = toolCalls
+	final.normalizeMessage()
+	return &final, nil
+}
+
+func (o *ollamaBackend) Generate(ctx context.Context, req *GenerateRequest) (*ChatResponse, error) {
+	payload := map[string]interface{}{
+		"model":      req.Model.Name,
+		"prompt":     req.Prompt,
+		"stream":     false,
+		"keep_alive": req.Model.Options.ollamaKeepAlive(),
+	}
+	if options := req.Model.Options.ollamaOptions(); len(options) > 0 {
+		payload["options"] = options
+	}
+	if req.System != "" {
+		payload["system"] = req.System
+	}
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.GenerateEndpoint, ollamaGenerateEndpoint), payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	var response ChatResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return &response, nil
+}
+
+func (o *ollamaBackend) Embed(ctx context.Context, model Model, content string) ([]float64, error) {
+	payload := map[string]interface{}{
+		"model":      model.Name,
+		"prompt":     content,
+		"input":      content,
+		"keep_alive": model.Options.ollamaKeepAlive(),
+	}
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.EmbeddingEndpoint, ollamaEmbedEndpoint), payload, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// /api/embeddings answers with "embedding", /api/embed with "embeddings".
+	var result struct {
+		Embedding  []float64   `json:"embedding"`
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding embedding: %w", err)
+	}
+	if len(result.Embedding) == 0 && len(result.Embeddings) > 0 {
+		return result.Embeddings[0], nil
+	}
+	return result.Embedding, nil
+}
+
+func (o *ollamaBackend) Tokenize(ctx context.Context, model Model, content string) ([]int, error) {
+	if o.provider.TokenizeEndpoint == "" {
+		return nil, ErrNotSupported
+	}
+	payload := map[string]interface{}{
+		"model":   model.Name,
+		"content": content,
+	}
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.TokenizeEndpoint, ""), payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding tokens: %w", err)
+	}
+	return result.Tokens, nil
+}
+
+func (o *ollamaBackend) ListModels(ctx context.Context) ([]*ModelInfo, error) {
+	body, err := getJSON(ctx, o.provider.endpointUrl(o.provider.ModelsEndpoint, ollamaTagsEndpoint), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []*ModelInfo `json:"models"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding model list: %w", err)
+	}
+	return result.Models, nil
+}
+
+// ShowModel looks the model up in /api/tags, so that models which were never
+// pulled fail with ErrModelNotFound, and reads its details from /api/show.
+func (o *ollamaBackend) ShowModel(ctx context.Context, name string) (*ModelInfo, error) {
+	models, err := o.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	model, err := findModel(models, name)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"model": model.Name,
+		"name":  model.Name, // Ollama before 0.3 only reads "name"
+	}
+	body, err := postJSON(ctx, o.provider.endpointUrl("", ollamaShowEndpoint), payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Capabilities  []string               `json:"capabilities"`
+		ModelInfo     map[string]interface{} `json:"model_info"`
+		ProjectorInfo map[string]interface{} `json:"projector_info"`
+		Template      string                 `json:"template"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding model details: %w", err)
+	}
+
+	info := &ModelInfo{Name: model.Name, Size: model.Size, Capabilities: result.Capabilities}
+	// model_info keys are prefixed with the architecture, e.g. "qwen3.context_length".
+	for key, value := range result.ModelInfo {
+		number, ok := value.(float64)
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(key, ".context_length"):
+			info.ContextLength = int(number)
+		case strings.HasSuffix(key, ".embedding_length"):
+			info.EmbeddingLength = int(number)
+		}
+	}
+	if info.Capabilities == nil {
+		// Servers older than 0.6.4 do not list capabilities; infer what we can.
+		info.Capabilities = []string{CapabilityCompletion}
+		if strings.Contains(result.Template, ".Tools") {
+			info.Capabilities = append(info.Capabilities, CapabilityTools)
+		}
+		if len(result.ProjectorInfo) > 0 {
+			info.Capabilities = append(info.Capabilities, CapabilityVision)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,101 @@
+package goAgent
+
+import "testing"
+
+func TestOllamaThinkFlag(t *testing.T) {
+	tests := []struct {
+		name  string
+		model Model
+		think interface{} // nil when the flag must not be sent
+	}{
+		{name: "plain model", model: Model{Name: "fake"}},
+		{name: "reasoning", model: Model{Name: "fake", Reasoning: true}, think: true},
+		{name: "thinking model", model: Model{Name: "fake", Capabilities: []string{CapabilityThinking}}, think: false},
+		{name: "thinking model reasoning", model: Model{Name: "fake", Reasoning: true, Capabilities: []string{CapabilityThinking}}, think: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+				return map[string]interface{}{"role": "assistant", "content": "ok"}
+			})
+			agent := fake.agent()
+			agent.Model = test.model
+			if _, err := NewChat(agent, nil).SendMessage("user", "hi", false); err != nil {
+				t.Fatal(err)
+			}
+			think, sent := fake.requests[0]["think"]
+			if sent != (test.think != nil) || think != test.think {
+				t.Fatalf("sent think %v (%t), want %v", think, sent, test.think)
+			}
+		})
+	}
+}
+
+func TestOllamaNativeToolCallsAndThinking(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    map[string]interface{}
+		content  string
+		thinking string
+		call     string // name and arguments of the single expected tool call
+	}{
+		{
+			name: "native fields",
+			reply: map[string]interface{}{
+				"role":       "assistant",
+				"content":    "It is sunny.",
+				"thinking":   "The user wants the weather.",
+				"tool_calls": callTool("weather", map[string]interface{}{"city": "Oslo"})["tool_calls"],
+			},
+			content:  "It is sunny.",
+			thinking: "The user wants the weather.",
+			call:     `weather {"city":"Oslo"}`,
+		},
+		{
+			name: "native fields win over tags",
+			reply: map[string]interface{}{
+				"role":       "assistant",
+				"content":    `<think>tagged</think><tool_call>{"name":"tagged","arguments":{}}</tool_call>It is sunny.`,
+				"thinking":   "native",
+				"tool_calls": callTool("weather", map[string]interface{}{"city": "Oslo"})["tool_calls"],
+			},
+			content:  "It is sunny.",
+			thinking: "native",
+			call:     `weather {"city":"Oslo"}`,
+		},
+		{
+			name: "tags without native fields",
+			reply: map[string]interface{}{
+				"role":    "assistant",
+				"content": "<think>\n  tagged \n</think>\n<tool_call>{\"name\":\"weather\",\"arguments\":{\"city\":\"Bergen\"}}</tool_call>\nIt rains.",
+			},
+			content:  "It rains.",
+			thinking: "tagged",
+			call:     `weather {"city":"Bergen"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} { return test.reply })
+			weather := NewTool("function", "weather", "reports the weather", func(*ToolInvocation) (map[string]interface{}, error) {
+				return map[string]interface{}{}, nil
+			})
+			for _, stream := range []bool{false, true} {
+				response, err := NewChat(fake.agent(weather), nil).SendMessage("user", "weather?", stream)
+				if err != nil {
+					t.Fatal(err)
+				}
+				message := response.Message
+				if message.Content != test.content || message.Thinking != test.thinking {
+					t.Fatalf("stream %t: got content %q and thinking %q", stream, message.Content, message.Thinking)
+				}
+				if len(message.ToolCalls) != 1 {
+					t.Fatalf("stream %t: got %d tool calls, want 1", stream, len(message.ToolCalls))
+				}
+				if call := message.ToolCalls[0].Name + " " + string(message.ToolCalls[0].Arguments); call != test.call {
+					t.Fatalf("stream %t: got tool call %s, want %s", stream, call, test.call)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	BackendOpenAI = "openai"
+
+	openAIChatEndpoint       = "/v1/chat/completions"
+	openAICompletionEndpoint = "/v1/completions"
+	openAIEmbeddingEndpoint  = "/v1/embeddings"
+	openAIModelsEndpoint     = "/v1/models"
+)
+
+// openAIBackend speaks the OpenAI Chat Completions protocol used by OpenAI,
+// vLLM, llama.cpp and other compatible servers.
+type openAIBackend struct {
+	provider *Provider
+}
+
+func newOpenAIBackend(provider *Provider) Backend {
+	return &openAIBackend{provider: provider}
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageUrl *openAIImageUrl `json:"image_url,omitempty"`
+}
+
+type openAIImageUrl struct {
+	Url string `json:"url"`
+}
+
+type openAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIToolFunction `json:"function"`
+}
+
+type openAIToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  ToolParameters `json:"parameters"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Message struct {
+			Role             string           `json:"role"`
+			Content          *string          `json:"content"`
+			ReasoningContent string           `json:"reasoning_content"`
+			ToolCalls        []openAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+func (o *openAIBackend) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	payload := map[string]interface{}{
+		"model":    req.Model.Name,
+		"messages": toOpenAIMessages(req.Messages),
+		"stream":   req.Stream,
+	}
+	req.Model.Options.applyOpenAI(payload)
+	if req.Format != nil {
+		payload["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "response",
+				"schema": req.Format,
+			},
+		}
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = toOpenAITools(req.Tools)
+	}
+
+	url := o.provider.endpointUrl(o.provider.ChatEndpoint, openAIChatEndpoint)
+	if req.Stream {
+		payload["stream_options"] = map[string]interface{}{"include_usage": true}
+		return o.chatStream(ctx, url, payload, req.OnEvent)
+	}
+	body, err := postJSON(ctx, url, payload, o.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result openAIChatResponse
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("response from %s contained no choices", result.Model)
+	}
+
+	choice := result.Choices[0]
+	response := &ChatResponse{
+		Model:           result.Model,
+		CreatedAt:       time.Unix(result.Created, 0),
+		Done:            true,
+		DoneReason:      choice.FinishReason,
+		PromptEvalCount: result.Usage.PromptTokens,
+		EvalCount:       result.Usage.CompletionTokens,
+		Message: Message{
+			Role:     choice.Message.Role,
+			Thinking: choice.Message.ReasoningContent,
+		},
+	}
+	if choice.Message.Content != nil {
+		response.Message.Content = *choice.Message.Content
+	}
+	for _, toolCall := range choice.Message.ToolCalls {
+		response.Message.ToolCalls = append(response.Message.ToolCalls, fromOpenAIToolCall(toolCall))
+	}
+
+	response.normalizeMessage()
+	return response, nil
+}
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Delta struct {
+			Role             string `json:"role"`
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// chatStream reads a server-sent event stream of chat completion chunks,
+// forwarding deltas to handler and stitching tool call fragments back together.
+func (o *openAIBackend) chatStream(ctx context.Context, url string, payload map[string]interface{}, handler StreamHandler) (*ChatResponse, error) {
+	body, err := postStream(ctx, url, payload, o.header())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	splitter := newTagSplitter(handler)
+	response := &ChatResponse{Done: true, Message: Message{Role: "assistant"}}
+	var content, thinking strings.Builder
+	var toolCalls []*openAIToolCall
+	err = readSSE(body, func(_ string, data []byte) error {
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("openai stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+			response.CreatedAt = time.Unix(chunk.Created, 0)
+		}
+		if chunk.Usage != nil {
+			response.PromptEvalCount = chunk.Usage.PromptTokens
+			response.EvalCount = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason != nil {
+			response.DoneReason = *choice.FinishReason
+		}
+		reasoning := choice.Delta.ReasoningContent + choice.Delta.Reasoning
+		if reasoning != "" {
+			thinking.WriteString(reasoning)
+			handler.emit(&StreamEvent{Type: StreamThinking, Delta: reasoning})
+		}
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+			splitter.feed(choice.Delta.Content)
+		}
+		for _, fragment := range choice.Delta.ToolCalls {
+			for len(toolCalls) <= fragment.Index {
+				toolCalls = append(toolCalls, &openAIToolCall{Type: "function"})
+			}
+			toolCall := toolCalls[fragment.Index]
+			if fragment.ID != "" {
+				toolCall.ID = fragment.ID
+			}
+			toolCall.Function.Name += fragment.Function.Name
+			toolCall.Function.Arguments += fragment.Function.Arguments
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	splitter.flush()
+
+	response.Message.Content = content.String()
+	response.Message.Thinking = thinking.String()
+	for _, toolCall := range toolCalls {
+		response.Message.ToolCalls = append(response.Message.ToolCalls, fromOpenAIToolCall(*toolCall))
+	}
+	response.normalizeMessage()
+	return response, nil
+}
+
+func (o *openAIBackend) Generate(ctx context.Context, req *GenerateRequest) (*ChatResponse, error) {
+	prompt := req.Prompt
+	if req.System != "" {
+		prompt = req.System + "\n\n" + prompt
+	}
+	payload := map[string]interface{}{
+		"model":  req.Model.Name,
+		"prompt": prompt,
+		"stream": false,
+	}
+	req.Model.Options.applyOpenAI(payload)
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.GenerateEndpoint, openAICompletionEndpoint), payload, o.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Model   string `json:"model"`
+		Created int64  `json:"created"`
+		Choices []struct {
+			Text         string `json:"text"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("response from %s contained no choices", result.Model)
+	}
+	return &ChatResponse{
+		Model:           result.Model,
+		CreatedAt:       time.Unix(result.Created, 0),
+		Response:        result.Choices[0].Text,
+		Done:            true,
+		DoneReason:      result.Choices[0].FinishReason,
+		PromptEvalCount: result.Usage.PromptTokens,
+		EvalCount:       result.Usage.CompletionTokens,
+	}, nil
+}
+
+func (o *openAIBackend) Embed(ctx context.Context, model Model, content string) ([]float64, error) {
+	payload := map[string]interface{}{
+		"model": model.Name,
+		"input": content,
+	}
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.EmbeddingEndpoint, openAIEmbeddingEndpoint), payload, o.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding embedding: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("embedding response contained no data")
+	}
+	return result.Data[0].Embedding, nil
+}
+
+// Tokenize uses the non-standard /tokenize endpoint exposed by vLLM ("prompt")
+// and llama.cpp ("content"); it must be configured explicitly on the provider.
+func (o *openAIBackend) Tokenize(ctx context.Context, model Model, content string) ([]int, error) {
+	if o.provider.TokenizeEndpoint == "" {
+		return nil, ErrNotSupported
+	}
+	payload := map[string]interface{}{
+		"model":   model.Name,
+		"prompt":  content,
+		"content": content,
+	}
+
+	body, err := postJSON(ctx, o.provider.endpointUrl(o.provider.TokenizeEndpoint, ""), payload, o.header())
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding tokens: %w", err)
+	}
+	return result.Tokens, nil
+}
+
+func (o *openAIBackend) ListModels(ctx context.Context) ([]*ModelInfo, error) {
+	body, err := getJSON(ctx, o.provider.endpointUrl(o.provider.ModelsEndpoint, openAIModelsEndpoint), o.header())
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []openAIModel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding model list: %w", err)
+	}
+	models := make([]*ModelInfo, 0, len(result.Data))
+	for _, model := range result.Data {
+		models = append(models, model.info())
+	}
+	return models, nil
+}
+
+// ShowModel finds the model in the model list; OpenAI-compatible servers have
+// no richer per-model endpoint.
+func (o *openAIBackend) ShowModel(ctx context.Context, name string) (*ModelInfo, error) {
+	models, err := o.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findModel(models, name)
+}
+
+// openAIModel is an entry of /v1/models. OpenAI itself only reports the id;
+// the other fields are extensions of vLLM (max_model_len), llama.cpp (meta)
+// and LM Studio or OpenRouter (context_length).
+type openAIModel struct {
+	ID            string `json:"id"`
+	MaxModelLen   int    `json:"max_model_len"`
+	ContextLength int    `json:"context_length"`
+	Meta          struct {
+		NCtxTrain int `json:"n_ctx_train"`
+		NEmbd     int `json:"n_embd"`
+	} `json:"meta"`
+}
+
+func (m openAIModel) info() *ModelInfo {
+	info := &ModelInfo{Name: m.ID, EmbeddingLength: m.Meta.NEmbd}
+	switch {
+	case m.MaxModelLen > 0:
+		info.ContextLength = m.MaxModelLen
+	case m.ContextLength > 0:
+		info.ContextLength = m.ContextLength
+	default:
+		info.ContextLength = m.Meta.NCtxTrain
+	}
+	return info
+}
+
+// header returns the bearer authorization header when the provider has an api key.
+func (o *openAIBackend) header() http.Header {
+	header := http.Header{}
+	if o.provider.ApiKey != "" {
+		header.Set("Authorization", "Bearer "+o.provider.ApiKey)
+	}
+	return header
+}
+
+func toOpenAIMessages(messages []*Message) []openAIMessage {
+	converted := make([]openAIMessage, 0, len(messages))
+	for _, message := range messages {
+		openAIMsg := openAIMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+		}
+		if len(message.Images) > 0 {
+			parts := []openAIContentPart{{Type: "text", Text: message.Content}}
+			for _, image := range message.Images {
+				parts = append(parts, openAIContentPart{
+					Type:     "image_url",
+					ImageUrl: &openAIImageUrl{Url: imageDataUrl(image)},
+				})
+			}
+			openAIMsg.Content = parts
+		}
+		for i, toolCall := range message.ToolCalls {
+			id := toolCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", i)
+			}
+			openAIMsg.ToolCalls = append(openAIMsg.ToolCalls, openAIToolCall{
+				ID:       id,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: toolCall.Name, Arguments: string(toolCall.argumentObject())},
+			})
+		}
+		converted = append(converted, openAIMsg)
+	}
+	return converted
+}
+
+func toOpenAITools(tools []*Tool) []openAITool {
+	converted := make([]openAITool, 0, len(tools))
+	for _, tool := range tools {
+		converted = append(converted, openAITool{
+			Type: "function",
+			Function: openAIToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters.Schema(),
+			},
+		})
+	}
+	return converted
+}
+
+func fromOpenAIToolCall(toolCall openAIToolCall) *ToolCall {
+	return NewToolCall(toolCall.ID, toolCall.Function.Name, []byte(toolCall.Function.Arguments))
+}
+
+// imageDataUrl turns a base64 image, as stored in Message.Images, into a data url.
+// Values that already are urls are passed through unchanged.
+func imageDataUrl(image string) string {
+	if strings.HasPrefix(image, "data:") || strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+		return image
+	}
+	mediaType := "image/png"
+	if decoded, err := base64.StdEncoding.DecodeString(image); err == nil {
+		mediaType = http.DetectContentType(decoded)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, image)
+}
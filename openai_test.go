@@ -0,0 +1,219 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeOpenAI serves the Chat Completions API, answering the n-th request with
+// the n-th reply. Replies to streaming requests are written as they are, so
+// they should hold the event stream. It records the decoded requests and headers.
+type fakeOpenAI struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	headers  []http.Header
+}
+
+func newFakeOpenAI(t *testing.T, replies ...string) *fakeOpenAI {
+	t.Helper()
+	fake := &fakeOpenAI{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != openAIChatEndpoint {
+			http.NotFound(w, r)
+			return
+		}
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, request)
+		fake.headers = append(fake.headers, r.Header.Clone())
+		n := len(fake.requests)
+		fake.mu.Unlock()
+		if n > len(replies) {
+			http.Error(w, "no reply left", http.StatusInternalServerError)
+			return
+		}
+		if request["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		fmt.Fprint(w, replies[n-1])
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeOpenAI) provider() *Provider {
+	return &Provider{Type: BackendOpenAI, BaseUrl: f.URL, ApiKey: "test-key"}
+}
+
+// chunks writes chunks as a Chat Completions event stream ending with [DONE].
+func chunks(chunks ...string) string {
+	var stream strings.Builder
+	for _, chunk := range chunks {
+		fmt.Fprintf(&stream, "data: %s\n\n", chunk)
+	}
+	stream.WriteString("data: [DONE]\n\n")
+	return stream.String()
+}
+
+func TestOpenAIRequestShape(t *testing.T) {
+	fake := newFakeOpenAI(t, `{"model":"gpt-test","created":1700000000,"choices":[{"message":{
+		"role":"assistant","content":null,"reasoning_content":"need the weather",
+		"tool_calls":[{"id":"call_w","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Oslo\"}"}}]},
+		"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":3}}`)
+	user := NewMessage("user", "what is this?")
+	user.AddImage("iVBORw0KGgo=")
+	assistant := NewMessage("assistant", "")
+	assistant.ToolCalls = []*ToolCall{NewToolCall("", "lookup", []byte(`"{\"q\":\"go\"}"`))}
+	result := NewMessage("tool", `{"result":{}}`)
+	result.ToolCallID = "call_0"
+	lookup := NewTool("function", "lookup", "looks things up", nil)
+	lookup.Function.Parameters = *NewToolParameters("object")
+	lookup.Function.Parameters.AddProperty("q", "string", "", nil, true)
+	format := map[string]interface{}{"type": "object"}
+
+	response, err := newOpenAIBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "gpt-test"},
+		Messages: []*Message{NewMessage("system", "be brief"), user, assistant, result},
+		Tools:    []*Tool{lookup},
+		Format:   format,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header := fake.headers[0]; header.Get("Authorization") != "Bearer test-key" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	request := fake.requests[0]
+	if request["model"] != "gpt-test" || request["stream"] != false {
+		t.Fatalf("unexpected model or stream in %v", request)
+	}
+	messages := request["messages"].([]interface{})
+	if len(messages) != 4 || messages[0].(map[string]interface{})["role"] != "system" {
+		t.Fatalf("got messages %v, want system, user, assistant and tool", messages)
+	}
+	parts := messages[1].(map[string]interface{})["content"].([]interface{})
+	image := parts[1].(map[string]interface{})
+	if len(parts) != 2 || image["type"] != "image_url" || !strings.HasPrefix(image["image_url"].(map[string]interface{})["url"].(string), "data:image/png;base64,") {
+		t.Fatalf("unexpected user content %v", parts)
+	}
+	call := messages[2].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})
+	function := call["function"].(map[string]interface{})
+	if call["id"] != "call_0" || function["name"] != "lookup" || function["arguments"] != `{"q":"go"}` {
+		t.Fatalf("unexpected tool call %v, want string arguments", call)
+	}
+	if messages[3].(map[string]interface{})["tool_call_id"] != "call_0" {
+		t.Fatalf("unexpected tool result %v", messages[3])
+	}
+
+	tool := request["tools"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})
+	if tool["name"] != "lookup" || tool["parameters"].(map[string]interface{})["required"].([]interface{})[0] != "q" {
+		t.Fatalf("unexpected tool %v", tool)
+	}
+	responseFormat := request["response_format"].(map[string]interface{})
+	schema := responseFormat["json_schema"].(map[string]interface{})
+	if responseFormat["type"] != "json_schema" || schema["name"] != "response" || schema["schema"].(map[string]interface{})["type"] != "object" {
+		t.Fatalf("unexpected response_format %v", responseFormat)
+	}
+
+	message := response.Message
+	if message.Content != "" || message.Thinking != "need the weather" || response.DoneReason != "tool_calls" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if response.PromptEvalCount != 10 || response.EvalCount != 3 || response.CreatedAt.Unix() != 1700000000 {
+		t.Fatalf("unexpected usage or time %+v", response)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].ID != "call_w" || string(message.ToolCalls[0].Arguments) != `{"city":"Oslo"}` {
+		t.Fatalf("unexpected tool calls %+v", message.ToolCalls)
+	}
+}
+
+func TestOpenAINoChoices(t *testing.T) {
+	fake := newFakeOpenAI(t, `{"model":"gpt-test","choices":[]}`)
+	_, err := newOpenAIBackend(fake.provider()).Chat(context.Background(), &ChatRequest{Model: Model{Name: "gpt-test"}})
+	if err == nil || !strings.Contains(err.Error(), "no choices") {
+		t.Fatalf("got %v, want the empty response reported", err)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	fake := newFakeOpenAI(t, chunks(
+		`{"model":"gpt-test","created":1700000000,"choices":[{"delta":{"role":"assistant"}}]}`,
+		`{"choices":[{"delta":{"reasoning_content":"think "}}]}`,
+		`{"choices":[{"delta":{"reasoning":"more"}}]}`,
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"look","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"up","arguments":"{\"q\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"weather","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":9}}`,
+	))
+	var content, thinking strings.Builder
+	response, err := newOpenAIBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "gpt-test"},
+		Messages: []*Message{NewMessage("user", "hi")},
+		Stream:   true,
+		OnEvent: func(event *StreamEvent) {
+			switch event.Type {
+			case StreamContent:
+				content.WriteString(event.Delta)
+			case StreamThinking:
+				thinking.WriteString(event.Delta)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options, _ := fake.requests[0]["stream_options"].(map[string]interface{}); options["include_usage"] != true {
+		t.Fatalf("usage was not requested: %v", fake.requests[0])
+	}
+
+	if content.String() != "Hello" || thinking.String() != "think more" {
+		t.Fatalf("streamed content %q and thinking %q", content.String(), thinking.String())
+	}
+	message := response.Message
+	if message.Role != "assistant" || message.Content != "Hello" || message.Thinking != "think more" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+	var calls []string
+	for _, call := range message.ToolCalls {
+		calls = append(calls, call.ID+" "+call.Name+" "+string(call.Arguments))
+	}
+	if got := strings.Join(calls, ", "); got != `call_a lookup {"q":"go"}, call_b weather {}` {
+		t.Fatalf("got tool calls %s", got)
+	}
+	if response.Model != "gpt-test" || response.DoneReason != "tool_calls" || response.PromptEvalCount != 7 || response.EvalCount != 9 {
+		t.Fatalf("unexpected model, stop reason or usage %+v", response)
+	}
+}
+
+func TestOpenAIStreamError(t *testing.T) {
+	fake := newFakeOpenAI(t, chunks(
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"error":{"message":"model overloaded"}}`,
+	))
+	_, err := newOpenAIBackend(fake.provider()).Chat(context.Background(), &ChatRequest{
+		Model:    Model{Name: "gpt-test"},
+		Messages: []*Message{NewMessage("user", "hi")},
+		Stream:   true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "model overloaded") {
+		t.Fatalf("got %v, want the stream error", err)
+	}
+}
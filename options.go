@@ -0,0 +1,196 @@
+package goAgent
+
+import (
+	"strconv"
+)
+
+// ModelOptions holds sampling and runtime settings for a model.
+// Nil fields are left to the provider's defaults; each backend translates the
+// rest to its native parameters and silently drops what it does not support.
+type ModelOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	TopK        *int     `json:"topK,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	NumCtx      *int     `json:"numCtx,omitempty"`     // context length to load the model with (Ollama only)
+	NumPredict  *int     `json:"numPredict,omitempty"` // maximum number of tokens to generate
+	KeepAlive   string   `json:"keepAlive,omitempty"`  // how long Ollama keeps the model loaded, e.g. "10m" or "-1"
+}
+
+// Ptr returns a pointer to v, for filling in ModelOptions fields inline.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Clone returns a deep copy of the options.
+func (o *ModelOptions) Clone() *ModelOptions {
+	if o == nil {
+		return nil
+	}
+	clone := &ModelOptions{
+		Temperature: clonePtr(o.Temperature),
+		TopP:        clonePtr(o.TopP),
+		TopK:        clonePtr(o.TopK),
+		Seed:        clonePtr(o.Seed),
+		NumCtx:      clonePtr(o.NumCtx),
+		NumPredict:  clonePtr(o.NumPredict),
+		KeepAlive:   o.KeepAlive,
+	}
+	if o.Stop != nil {
+		clone.Stop = append([]string(nil), o.Stop...)
+	}
+	return clone
+}
+
+// Merge returns a new set of options with every field set in override taking
+// precedence over o. Either side may be nil.
+func (o *ModelOptions) Merge(override *ModelOptions) *ModelOptions {
+	merged := o.Clone()
+	if override == nil {
+		return merged
+	}
+	if merged == nil {
+		return override.Clone()
+	}
+	if override.Temperature != nil {
+		merged.Temperature = clonePtr(override.Temperature)
+	}
+	if override.TopP != nil {
+		merged.TopP = clonePtr(override.TopP)
+	}
+	if override.TopK != nil {
+		merged.TopK = clonePtr(override.TopK)
+	}
+	if override.Seed != nil {
+		merged.Seed = clonePtr(override.Seed)
+	}
+	if override.Stop != nil {
+		merged.Stop = append([]string(nil), override.Stop...)
+	}
+	if override.NumCtx != nil {
+		merged.NumCtx = clonePtr(override.NumCtx)
+	}
+	if override.NumPredict != nil {
+		merged.NumPredict = clonePtr(override.NumPredict)
+	}
+	if override.KeepAlive != "" {
+		merged.KeepAlive = override.KeepAlive
+	}
+	return merged
+}
+
+// ollamaOptions converts the options to Ollama's "options" object.
+func (o *ModelOptions) ollamaOptions() map[string]interface{} {
+	options := map[string]interface{}{}
+	if o == nil {
+		return options
+	}
+	if o.Temperature != nil {
+		options["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		options["top_p"] = *o.TopP
+	}
+	if o.TopK != nil {
+		options["top_k"] = *o.TopK
+	}
+	if o.Seed != nil {
+		options["seed"] = *o.Seed
+	}
+	if len(o.Stop) > 0 {
+		options["stop"] = o.Stop
+	}
+	if o.NumCtx != nil {
+		options["num_ctx"] = *o.NumCtx
+	}
+	if o.NumPredict != nil {
+		options["num_predict"] = *o.NumPredict
+	}
+	return options
+}
+
+// ollamaKeepAlive returns the keep_alive value to send, keeping models loaded
+// indefinitely (-1) unless configured otherwise.
+func (o *ModelOptions) ollamaKeepAlive() interface{} {
+	if o == nil || o.KeepAlive == "" {
+		return -1
+	}
+	if seconds, err := strconv.Atoi(o.KeepAlive); err == nil {
+		return seconds
+	}
+	return o.KeepAlive
+}
+
+// applyOpenAI sets the options supported by OpenAI-compatible servers on payload.
+// top_k is not part of the OpenAI API but is honoured by vLLM and llama.cpp.
+func (o *ModelOptions) applyOpenAI(payload map[string]interface{}) {
+	if o == nil {
+		return
+	}
+	if o.Temperature != nil {
+		payload["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		payload["top_p"] = *o.TopP
+	}
+	if o.TopK != nil {
+		payload["top_k"] = *o.TopK
+	}
+	if o.Seed != nil {
+		payload["seed"] = *o.Seed
+	}
+	if len(o.Stop) > 0 {
+		payload["stop"] = o.Stop
+	}
+	if o.NumPredict != nil {
+		payload["max_tokens"] = *o.NumPredict
+	}
+}
+
+// applyAnthropic sets max_tokens, the sampling options and, when thinking is
+// requested, the thinking budget on payload. Anthropic rejects temperature,
+// top_k and top_p changes while extended thinking is on, so they are dropped
+// in that case, as is thinking itself when max_tokens leaves no room for it.
+func (o *ModelOptions) applyAnthropic(payload map[string]interface{}, thinking bool) {
+	maxTokens := anthropicDefaultMaxTokens
+	if o != nil && o.NumPredict != nil {
+		maxTokens = *o.NumPredict
+	}
+	payload["max_tokens"] = maxTokens
+
+	budget := min(anthropicDefaultThinkingBudget, maxTokens-1)
+	thinking = thinking && budget >= anthropicMinThinkingBudget
+	if thinking {
+		payload["thinking"] = map[string]interface{}{
+			"type":          "enabled",
+			"budget_tokens": budget,
+		}
+	}
+	if o == nil {
+		return
+	}
+	if len(o.Stop) > 0 {
+		payload["stop_sequences"] = o.Stop
+	}
+	if thinking {
+		return
+	}
+	if o.Temperature != nil {
+		payload["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		payload["top_p"] = *o.TopP
+	}
+	if o.TopK != nil {
+		payload["top_k"] = *o.TopK
+	}
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	clone := *v
+	return &clone
+}
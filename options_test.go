@@ -0,0 +1,155 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModelOptionsMerge(t *testing.T) {
+	base := &ModelOptions{Temperature: Ptr(0.2), TopK: Ptr(40), Stop: []string{"END"}, KeepAlive: "10m"}
+	tests := []struct {
+		name     string
+		base     *ModelOptions
+		override *ModelOptions
+		want     string
+	}{
+		{name: "both nil", want: `null`},
+		{name: "no override", base: base, want: `{"temperature":0.2,"topK":40,"stop":["END"],"keepAlive":"10m"}`},
+		{name: "no base", override: base, want: `{"temperature":0.2,"topK":40,"stop":["END"],"keepAlive":"10m"}`},
+		{name: "empty override", base: base, override: &ModelOptions{}, want: `{"temperature":0.2,"topK":40,"stop":["END"],"keepAlive":"10m"}`},
+		{
+			name:     "override wins",
+			base:     base,
+			override: &ModelOptions{Temperature: Ptr(0.0), TopP: Ptr(0.9), Seed: Ptr(7), Stop: []string{}, NumCtx: Ptr(8192), NumPredict: Ptr(100), KeepAlive: "-1"},
+			want:     `{"temperature":0,"topP":0.9,"topK":40,"seed":7,"numCtx":8192,"numPredict":100,"keepAlive":"-1"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := test.base.Merge(test.override)
+			if got, _ := json.Marshal(merged); string(got) != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+			if merged != nil && (merged == test.base || merged == test.override) {
+				t.Fatal("Merge returned one of its inputs")
+			}
+		})
+	}
+
+	merged := base.Merge(&ModelOptions{Seed: Ptr(1)})
+	*merged.Temperature = 1
+	merged.Stop[0] = "STOP"
+	if *base.Temperature != 0.2 || base.Stop[0] != "END" {
+		t.Fatalf("changing the merged options changed the base: %+v", base)
+	}
+}
+
+func TestOllamaKeepAlive(t *testing.T) {
+	for keepAlive, want := range map[string]interface{}{"": -1, "300": 300, "-1": -1, "10m": "10m"} {
+		if got := (&ModelOptions{KeepAlive: keepAlive}).ollamaKeepAlive(); got != want {
+			t.Errorf("keepAlive %q sent as %v, want %v", keepAlive, got, want)
+		}
+	}
+	if got := (*ModelOptions)(nil).ollamaKeepAlive(); got != -1 {
+		t.Errorf("nil options sent keep_alive %v", got)
+	}
+}
+
+// agentOptions and chatOptions are the options of an agent's model and the
+// override of one of its chats, used by the payload tests below.
+var (
+	agentOptions = &ModelOptions{Temperature: Ptr(0.2), TopP: Ptr(0.5), TopK: Ptr(40), NumCtx: Ptr(8192), NumPredict: Ptr(3000), KeepAlive: "10m"}
+	chatOptions  = &ModelOptions{Temperature: Ptr(1.2), Seed: Ptr(7), Stop: []string{"END"}}
+)
+
+// sendWithOptions sends a message through a chat using override for an agent
+// using agentOptions, and checks that the agent's options are left alone.
+func sendWithOptions(t *testing.T, agent *Agent, override *ModelOptions) {
+	t.Helper()
+	agent.Model.Options = agentOptions.Clone()
+	chat := NewChat(agent, nil)
+	chat.Options = override
+	if _, err := chat.SendMessage("user", "hi", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(agent.Model.Options); string(got) != `{"temperature":0.2,"topP":0.5,"topK":40,"numCtx":8192,"numPredict":3000,"keepAlive":"10m"}` {
+		t.Fatalf("the chat changed the agent's options to %s", got)
+	}
+}
+
+// payloadFields returns the named fields of a recorded request as JSON.
+func payloadFields(request map[string]interface{}, names ...string) string {
+	fields := map[string]interface{}{}
+	for _, name := range names {
+		if value, ok := request[name]; ok {
+			fields[name] = value
+		}
+	}
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+func TestOllamaOptionsPayload(t *testing.T) {
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"role": "assistant", "content": "ok"}
+	})
+	sendWithOptions(t, fake.agent(), chatOptions)
+	want := `{"keep_alive":"10m","options":{"num_ctx":8192,"num_predict":3000,"seed":7,"stop":["END"],"temperature":1.2,"top_k":40,"top_p":0.5}}`
+	if got := payloadFields(fake.requests[0], "options", "keep_alive", "temperature", "max_tokens"); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if _, err := NewChat(fake.agent(), nil).SendMessage("user", "hi", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := payloadFields(fake.requests[1], "options", "keep_alive"); got != `{"keep_alive":-1}` {
+		t.Fatalf("a model without options sent %s", got)
+	}
+}
+
+func TestOpenAIOptionsPayload(t *testing.T) {
+	fake := newFakeOpenAI(t, `{"model":"gpt-test","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	sendWithOptions(t, &Agent{Name: "OpenAI", Model: Model{Name: "gpt-test", ContextWindow: 4096}, Provider: fake.provider()}, chatOptions)
+	want := `{"max_tokens":3000,"seed":7,"stop":["END"],"temperature":1.2,"top_k":40,"top_p":0.5}`
+	if got := payloadFields(fake.requests[0], "temperature", "top_p", "top_k", "seed", "stop", "max_tokens", "options", "num_ctx", "keep_alive"); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestAnthropicOptionsPayload(t *testing.T) {
+	fields := []string{"max_tokens", "temperature", "top_p", "top_k", "stop_sequences", "thinking", "seed"}
+	tests := []struct {
+		name      string
+		reasoning bool
+		maxTokens *int
+		want      string
+	}{
+		{name: "sampling", want: `{"max_tokens":3000,"stop_sequences":["END"],"temperature":1.2,"top_k":40,"top_p":0.5}`},
+		{name: "thinking", reasoning: true, want: `{"max_tokens":3000,"stop_sequences":["END"],"thinking":{"budget_tokens":2048,"type":"enabled"}}`},
+		{name: "small thinking budget", reasoning: true, maxTokens: Ptr(1500), want: `{"max_tokens":1500,"stop_sequences":["END"],"thinking":{"budget_tokens":1499,"type":"enabled"}}`},
+		{name: "no room to think", reasoning: true, maxTokens: Ptr(1000), want: `{"max_tokens":1000,"stop_sequences":["END"],"temperature":1.2,"top_k":40,"top_p":0.5}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeAnthropic(t, anthropicTextReply)
+			agent := &Agent{Name: "Claude", Model: Model{Name: "claude-test", ContextWindow: 4096, Reasoning: test.reasoning}, Provider: fake.provider()}
+			override := chatOptions
+			if test.maxTokens != nil {
+				override = chatOptions.Merge(&ModelOptions{NumPredict: test.maxTokens})
+			}
+			sendWithOptions(t, agent, override)
+			if got := payloadFields(fake.requests[0], fields...); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	fake := newFakeAnthropic(t, anthropicTextReply)
+	agent := &Agent{Name: "Claude", Model: Model{Name: "claude-test", ContextWindow: 4096}, Provider: fake.provider()}
+	if _, err := NewChat(agent, nil).SendMessage("user", "hi", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := payloadFields(fake.requests[0], fields...); got != `{"max_tokens":4096}` {
+		t.Fatalf("a model without options sent %s", got)
+	}
+}
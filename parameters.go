@@ -0,0 +1,295 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolParameterProperty is the JSON Schema of a single tool parameter. It nests:
+// arrays describe their elements with Items, objects their fields with Properties.
+type ToolParameterProperty struct {
+	Type                 string                            `json:"type,omitempty"`
+	Description          string                            `json:"description,omitempty"`
+	Enum                 []interface{}                     `json:"enum,omitempty"` // JSON values; see WithEnum
+	Format               string                            `json:"format,omitempty"`
+	Default              interface{}                       `json:"default,omitempty"`
+	Items                *ToolParameterProperty            `json:"items,omitempty"`
+	Properties           map[string]*ToolParameterProperty `json:"properties,omitempty"`
+	AdditionalProperties interface{}                       `json:"additionalProperties,omitempty"` // false or a schema
+	OneOf                []*ToolParameterProperty          `json:"oneOf,omitempty"`
+	Minimum              *float64                          `json:"minimum,omitempty"`
+	Maximum              *float64                          `json:"maximum,omitempty"`
+	MinLength            *int                              `json:"minLength,omitempty"`
+	MaxLength            *int                              `json:"maxLength,omitempty"`
+	Pattern              string                            `json:"pattern,omitempty"`
+	MinItems             *int                              `json:"minItems,omitempty"`
+	MaxItems             *int                              `json:"maxItems,omitempty"`
+
+	// RequiredProperties lists the required fields of an object property; it is
+	// written as the schema's "required" array.
+	RequiredProperties []string `json:"-"`
+	// Required marks the property itself as required by its parent. It is read
+	// from the legacy `"required": true` form and never written.
+	Required bool `json:"-"`
+}
+
+func NewToolParameterProperty(Type, description string, enum []string, required bool) *ToolParameterProperty {
+	return &ToolParameterProperty{
+		Type:        Type,
+		Description: description,
+		Enum:        schemaValues(enum),
+		Required:    required,
+	}
+}
+
+func NewStringProperty(description string) *ToolParameterProperty {
+	return &ToolParameterProperty{Type: "string", Description: description}
+}
+
+func NewIntegerProperty(description string) *ToolParameterProperty {
+	return &ToolParameterProperty{Type: "integer", Description: description}
+}
+
+func NewNumberProperty(description string) *ToolParameterProperty {
+	return &ToolParameterProperty{Type: "number", Description: description}
+}
+
+func NewBooleanProperty(description string) *ToolParameterProperty {
+	return &ToolParameterProperty{Type: "boolean", Description: description}
+}
+
+// NewArrayProperty describes a list whose elements match items.
+func NewArrayProperty(description string, items *ToolParameterProperty) *ToolParameterProperty {
+	return &ToolParameterProperty{Type: "array", Description: description, Items: items}
+}
+
+// NewObjectProperty describes a nested object; add its fields with WithProperty.
+func NewObjectProperty(description string) *ToolParameterProperty {
+	return &ToolParameterProperty{
+		Type:        "object",
+		Description: description,
+		Properties:  make(map[string]*ToolParameterProperty),
+	}
+}
+
+// NewOneOfProperty describes a value that must match one of options; the first match wins.
+func NewOneOfProperty(description string, options ...*ToolParameterProperty) *ToolParameterProperty {
+	return &ToolParameterProperty{Description: description, OneOf: options}
+}
+
+// WithProperty adds a field to an object property.
+func (property *ToolParameterProperty) WithProperty(name string, field *ToolParameterProperty, required bool) *ToolParameterProperty {
+	if property.Properties == nil {
+		property.Properties = make(map[string]*ToolParameterProperty)
+	}
+	field.Required = required
+	property.Properties[name] = field
+	if required {
+		property.RequiredProperties = append(property.RequiredProperties, name)
+	}
+	return property
+}
+
+// WithEnum limits the property to values, which are compared as JSON values:
+// WithEnum(1, 2, 3) on an integer property allows the numbers 1, 2 and 3.
+func (property *ToolParameterProperty) WithEnum(values ...interface{}) *ToolParameterProperty {
+	property.Enum = values
+	return property
+}
+
+func (property *ToolParameterProperty) WithDefault(value interface{}) *ToolParameterProperty {
+	property.Default = value
+	return property
+}
+
+func (property *ToolParameterProperty) WithFormat(format string) *ToolParameterProperty {
+	property.Format = format
+	return property
+}
+
+// WithRange bounds a number or integer property, inclusive.
+func (property *ToolParameterProperty) WithRange(minimum, maximum float64) *ToolParameterProperty {
+	property.Minimum = &minimum
+	property.Maximum = &maximum
+	return property
+}
+
+// WithLength bounds the length of a string property.
+func (property *ToolParameterProperty) WithLength(minLength, maxLength int) *ToolParameterProperty {
+	property.MinLength = &minLength
+	property.MaxLength = &maxLength
+	return property
+}
+
+// WithPattern requires a string property to match the regular expression.
+func (property *ToolParameterProperty) WithPattern(pattern string) *ToolParameterProperty {
+	property.Pattern = pattern
+	return property
+}
+
+// WithItemCount bounds the number of elements of an array property.
+func (property *ToolParameterProperty) WithItemCount(minItems, maxItems int) *ToolParameterProperty {
+	property.MinItems = &minItems
+	property.MaxItems = &maxItems
+	return property
+}
+
+// Clone returns a deep copy of the property. Default and JSON-valued
+// AdditionalProperties are shared; they are treated as read-only.
+func (property *ToolParameterProperty) Clone() *ToolParameterProperty {
+	if property == nil {
+		return nil
+	}
+	clone := *property
+	if property.Enum != nil {
+		clone.Enum = append([]interface{}(nil), property.Enum...)
+	}
+	clone.RequiredProperties = cloneStrings(property.RequiredProperties)
+	clone.Items = property.Items.Clone()
+	clone.Properties = cloneProperties(property.Properties)
+	if additional, ok := property.AdditionalProperties.(*ToolParameterProperty); ok {
+		clone.AdditionalProperties = additional.Clone()
+	}
+	if property.OneOf != nil {
+		clone.OneOf = make([]*ToolParameterProperty, len(property.OneOf))
+		for i, option := range property.OneOf {
+			clone.OneOf[i] = option.Clone()
+		}
+	}
+	clone.Minimum = clonePtr(property.Minimum)
+	clone.Maximum = clonePtr(property.Maximum)
+	clone.MinLength = clonePtr(property.MinLength)
+	clone.MaxLength = clonePtr(property.MaxLength)
+	clone.MinItems = clonePtr(property.MinItems)
+	clone.MaxItems = clonePtr(property.MaxItems)
+	return &clone
+}
+
+// Clone returns a deep copy of the parameters.
+func (toolParameters ToolParameters) Clone() ToolParameters {
+	if additional, ok := toolParameters.AdditionalProperties.(*ToolParameterProperty); ok {
+		toolParameters.AdditionalProperties = additional.Clone()
+	}
+	toolParameters.Properties = cloneProperties(toolParameters.Properties)
+	toolParameters.Required = cloneStrings(toolParameters.Required)
+	return toolParameters
+}
+
+func cloneProperties(properties map[string]*ToolParameterProperty) map[string]*ToolParameterProperty {
+	if properties == nil {
+		return nil
+	}
+	clone := make(map[string]*ToolParameterProperty, len(properties))
+	for name, property := range properties {
+		clone[name] = property.Clone()
+	}
+	return clone
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
+
+// schemaType returns the declared type, inferring array or object from Items or Properties.
+func (property *ToolParameterProperty) schemaType() string {
+	switch {
+	case property.Type != "":
+		return property.Type
+	case property.Items != nil:
+		return "array"
+	case property.Properties != nil:
+		return "object"
+	}
+	return ""
+}
+
+// requiredNames merges RequiredProperties with fields flagged as required.
+func (property *ToolParameterProperty) requiredNames() []string {
+	return mergeRequired(property.RequiredProperties, property.Properties)
+}
+
+type toolParameterPropertyJSON ToolParameterProperty
+
+// MarshalJSON writes the property as JSON Schema.
+func (property *ToolParameterProperty) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*toolParameterPropertyJSON
+		Type     string   `json:"type,omitempty"`
+		Required []string `json:"required,omitempty"`
+	}{
+		toolParameterPropertyJSON: (*toolParameterPropertyJSON)(property),
+		Type:                      property.schemaType(),
+		Required:                  property.requiredNames(),
+	})
+}
+
+// UnmarshalJSON reads JSON Schema, accepting "required" both as the list of
+// required fields and as the legacy boolean on the property itself.
+func (property *ToolParameterProperty) UnmarshalJSON(data []byte) error {
+	wire := struct {
+		*toolParameterPropertyJSON
+		Required json.RawMessage `json:"required,omitempty"`
+	}{toolParameterPropertyJSON: (*toolParameterPropertyJSON)(property)}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	if len(wire.Required) > 0 {
+		if err := json.Unmarshal(wire.Required, &property.Required); err != nil {
+			if err = json.Unmarshal(wire.Required, &property.RequiredProperties); err != nil {
+				return fmt.Errorf("required must be a boolean or a list of property names: %w", err)
+			}
+		}
+	}
+	property.RequiredProperties = markRequired(property.RequiredProperties, property.Properties)
+	if len(property.RequiredProperties) == 0 {
+		property.RequiredProperties = nil
+	}
+	return nil
+}
+
+type toolParametersJSON ToolParameters
+
+// MarshalJSON writes the parameters with every required property in the required list.
+// Properties and the required list are left out only when they are nil, so
+// UnmarshalJSON gives back parameters equal to the ones written.
+func (toolParameters ToolParameters) MarshalJSON() ([]byte, error) {
+	wire := struct {
+		Type                 string                             `json:"type"`
+		Properties           *map[string]*ToolParameterProperty `json:"properties,omitempty"`
+		Required             *[]string                          `json:"required,omitempty"`
+		AdditionalProperties interface{}                        `json:"additionalProperties,omitempty"`
+	}{Type: toolParameters.Type, AdditionalProperties: toolParameters.AdditionalProperties}
+	if toolParameters.Properties != nil {
+		wire.Properties = &toolParameters.Properties
+	}
+	if required := toolParameters.requiredNames(); toolParameters.Required != nil || len(required) > 0 {
+		wire.Required = &required
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON reads the parameters and flags the properties named in the required list.
+func (toolParameters *ToolParameters) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*toolParametersJSON)(toolParameters)); err != nil {
+		return err
+	}
+	required := markRequired(toolParameters.Required, toolParameters.Properties)
+	if toolParameters.Required != nil || len(required) > 0 {
+		toolParameters.Required = required
+	}
+	return nil
+}
+
+// markRequired flags the properties listed in required, so a schema read from
+// JSON looks the same as one built with AddProperty, and returns the full list.
+func markRequired(required []string, properties map[string]*ToolParameterProperty) []string {
+	for _, name := range required {
+		if property := properties[name]; property != nil {
+			property.Required = true
+		}
+	}
+	return mergeRequired(required, properties)
+}
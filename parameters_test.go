@@ -0,0 +1,139 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToolParametersRequiredForms(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string // the schema as written back
+	}{
+		{
+			name:   "list",
+			schema: `{"type":"object","properties":{"q":{"type":"string"},"n":{"type":"integer"}},"required":["q"]}`,
+			want:   `{"type":"object","properties":{"n":{"type":"integer"},"q":{"type":"string"}},"required":["q"]}`,
+		},
+		{
+			name:   "legacy boolean",
+			schema: `{"type":"object","properties":{"q":{"type":"string","required":true},"n":{"type":"integer","required":false}}}`,
+			want:   `{"type":"object","properties":{"n":{"type":"integer"},"q":{"type":"string"}},"required":["q"]}`,
+		},
+		{
+			name:   "both",
+			schema: `{"type":"object","properties":{"q":{"type":"string"},"n":{"type":"integer","required":true}},"required":["q"]}`,
+			want:   `{"type":"object","properties":{"n":{"type":"integer"},"q":{"type":"string"}},"required":["q","n"]}`,
+		},
+		{
+			name:   "nested list",
+			schema: `{"type":"object","properties":{"filter":{"type":"object","properties":{"site":{"type":"string"}},"required":["site"]}}}`,
+			want:   `{"type":"object","properties":{"filter":{"type":"object","properties":{"site":{"type":"string"}},"required":["site"]}}}`,
+		},
+		{
+			name:   "nested boolean",
+			schema: `{"type":"object","properties":{"filter":{"properties":{"site":{"type":"string","required":true}}}}}`,
+			want:   `{"type":"object","properties":{"filter":{"properties":{"site":{"type":"string"}},"type":"object","required":["site"]}}}`,
+		},
+		{
+			name:   "empty list",
+			schema: `{"type":"object","properties":{},"required":[]}`,
+			want:   `{"type":"object","properties":{},"required":[]}`,
+		},
+		{name: "bare", schema: `{"type":"object"}`, want: `{"type":"object"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parameters ToolParameters
+			if err := json.Unmarshal([]byte(test.schema), &parameters); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(parameters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mustCompact(t, string(data)) != mustCompact(t, test.want) {
+				t.Fatalf("got %s, want %s", data, test.want)
+			}
+
+			var read ToolParameters
+			if err = json.Unmarshal(data, &read); err != nil {
+				t.Fatal(err)
+			}
+			if again, _ := json.Marshal(read); string(again) != string(data) {
+				t.Fatalf("wrote %s, read back and wrote %s", data, again)
+			}
+		})
+	}
+}
+
+func TestToolParametersRequiredFlags(t *testing.T) {
+	var parameters ToolParameters
+	err := json.Unmarshal([]byte(`{"type":"object","properties":{"q":{"type":"string"},"n":{"type":"integer"},
+		"filter":{"type":"object","properties":{"site":{"type":"string"}},"required":["site"]}},"required":["q"]}`), &parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parameters.Properties["q"].Required || parameters.Properties["n"].Required {
+		t.Fatalf("required list was not applied to the properties: %+v", parameters.Properties)
+	}
+	filter := parameters.Properties["filter"]
+	if !filter.Properties["site"].Required || !reflect.DeepEqual(filter.RequiredProperties, []string{"site"}) {
+		t.Fatalf("nested required list was not applied: %+v", filter)
+	}
+
+	// Built with the helpers, the same schema marshals identically.
+	built := *NewToolParameters("object")
+	built.AddProperty("q", "string", "", nil, true)
+	built.AddProperty("n", "integer", "", nil, false)
+	built.SetProperty("filter", NewObjectProperty("").WithProperty("site", NewStringProperty(""), true), false)
+	builtData, _ := json.Marshal(built)
+	parsedData, _ := json.Marshal(parameters)
+	if mustCompact(t, string(builtData)) != mustCompact(t, string(parsedData)) {
+		t.Fatalf("built %s, parsed %s", builtData, parsedData)
+	}
+}
+
+func TestToolParametersMalformed(t *testing.T) {
+	for _, schema := range []string{
+		`{"type":"object","properties":{"q":{"type":"string","required":"yes"}}}`,
+		`{"type":"object","properties":{"q":{"type":"string","required":[1]}}}`,
+		`{"type":"object","properties":{"q":"string"}}`,
+		`{"type":"object","properties":[]}`,
+		`{"type":"object","required":"q"}`,
+		`{"type":"object","properties":{"q":{"type":"string","enum":"a"}}}`,
+		`[]`,
+	} {
+		var parameters ToolParameters
+		if err := json.Unmarshal([]byte(schema), &parameters); err == nil {
+			t.Errorf("%s was accepted as %+v", schema, parameters)
+		}
+	}
+}
+
+func TestToolParameterPropertyRoundTrip(t *testing.T) {
+	property := NewObjectProperty("a point").
+		WithProperty("x", NewNumberProperty("").WithRange(-1, 1), true).
+		WithProperty("label", NewStringProperty("").WithLength(1, 8).WithPattern(`^[a-z]+$`).WithDefault("p"), false).
+		WithProperty("tags", NewArrayProperty("", NewStringProperty("").WithEnum("a", "b")).WithItemCount(0, 2), false).
+		WithProperty("id", &ToolParameterProperty{OneOf: []*ToolParameterProperty{NewIntegerProperty(""), NewStringProperty("").WithFormat("uuid")}}, false)
+	property.AdditionalProperties = false
+
+	data, err := json.Marshal(property)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read ToolParameterProperty
+	if err = json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := json.Marshal(&read)
+	if string(again) != string(data) {
+		t.Fatalf("wrote %s, read back and wrote %s", data, again)
+	}
+	if !read.Properties["x"].Required || read.Properties["label"].Required || len(read.Properties["id"].OneOf) != 2 {
+		t.Fatalf("unexpected property read back: %+v", read)
+	}
+}
@@ -0,0 +1,114 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ToolPolicy decides whether a tool call runs without asking, needs approval or is refused.
+type ToolPolicy string
+
+const (
+	ToolAllow ToolPolicy = "allow" // the default
+	ToolAsk   ToolPolicy = "ask"   // the chat's Approver decides each call
+	ToolDeny  ToolPolicy = "deny"
+)
+
+// Approval is an Approver's decision on a single tool call.
+type Approval struct {
+	Approved  bool
+	Arguments json.RawMessage // replaces the call's arguments when set
+	Reason    string          // sent to the model when the call is denied
+}
+
+// Approver is asked before a tool with the ToolAsk policy runs.
+type Approver interface {
+	Approve(ctx context.Context, tool *Tool, call *ToolCall) (Approval, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, tool *Tool, call *ToolCall) (Approval, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, tool *Tool, call *ToolCall) (Approval, error) {
+	return f(ctx, tool, call)
+}
+
+// ToolDeniedError reports a tool call that was not run because of its policy or its approver.
+type ToolDeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *ToolDeniedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("call to tool %s was denied", e.Tool)
+	}
+	return fmt.Sprintf("call to tool %s was denied: %s", e.Tool, e.Reason)
+}
+
+// SetPolicy overrides the policy of the named tool for chats using this registry.
+func (tr *ToolRegistry) SetPolicy(name string, policy ToolPolicy) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	updated := make(map[string]ToolPolicy, len(tr.policies)+1)
+	for tool, existing := range tr.policies {
+		updated[tool] = existing
+	}
+	updated[name] = policy
+	tr.policies = updated
+}
+
+// Policy returns the registry's override of the named tool's policy.
+func (tr *ToolRegistry) Policy(name string) (ToolPolicy, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	policy, ok := tr.policies[name]
+	return policy, ok
+}
+
+// toolPolicy returns the policy for tool: the override of the registry it was
+// found in, then the tool's own policy, then ToolAllow.
+func toolPolicy(tool *Tool, registry *ToolRegistry) ToolPolicy {
+	if registry != nil {
+		if policy, ok := registry.Policy(tool.Function.Name); ok && policy != "" {
+			return policy
+		}
+	}
+	if tool.Policy != "" {
+		return tool.Policy
+	}
+	return ToolAllow
+}
+
+// authorize applies the tool's policy in registry to call, asking the chat's
+// Approver when needed. An approver may rewrite the call's arguments.
+func (c *Chat) authorize(ctx context.Context, tool *Tool, registry *ToolRegistry, call *ToolCall) error {
+	switch policy := toolPolicy(tool, registry); policy {
+	case ToolAllow:
+		return nil
+	case ToolDeny:
+		return &ToolDeniedError{Tool: tool.Function.Name, Reason: "the tool is disabled"}
+	case ToolAsk:
+		if c.Approver == nil {
+			return &ToolDeniedError{Tool: tool.Function.Name, Reason: "it needs approval and no approver is configured"}
+		}
+		approval, err := c.Approver.Approve(ctx, tool, call)
+		if err != nil {
+			return &ToolDeniedError{Tool: tool.Function.Name, Reason: err.Error()}
+		}
+		if !approval.Approved {
+			reason := approval.Reason
+			if reason == "" {
+				reason = "the user declined it"
+			}
+			return &ToolDeniedError{Tool: tool.Function.Name, Reason: reason}
+		}
+		if approval.Arguments != nil {
+			call.Arguments = normalizeArguments(approval.Arguments)
+		}
+		return nil
+	default:
+		return &ToolDeniedError{Tool: tool.Function.Name, Reason: fmt.Sprintf("unknown policy %q", policy)}
+	}
+}
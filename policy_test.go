@@ -0,0 +1,64 @@
+package goAgent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRegistryPoliciesApplyWhereToolIsFound(t *testing.T) {
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		return callTool("echo", map[string]interface{}{})
+	})
+	var calls int32
+	echo := NewTool("function", "echo", "echoes", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return map[string]interface{}{}, nil
+	})
+
+	cases := map[string]func() (*Chat, *ToolRegistry){
+		"agent registry": func() (*Chat, *ToolRegistry) {
+			agent := fake.agent(echo)
+			agent.Tools.SetPolicy("echo", ToolDeny)
+			return NewChat(agent, nil), nil
+		},
+		"per-call registry": func() (*Chat, *ToolRegistry) {
+			override := NewToolRegistry(echo)
+			override.SetPolicy("echo", ToolDeny)
+			return NewChat(fake.agent(echo), nil), override
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			chat, override := setup()
+			if _, err := chat.SendMessageWithTools(context.Background(), "user", "hi", override, false); err != nil {
+				t.Fatal(err)
+			}
+			if n := atomic.LoadInt32(&calls); n != 0 {
+				t.Fatalf("denied tool was called %d times", n)
+			}
+			last := chat.Messages[len(chat.Messages)-1]
+			if last.Role != "tool" || !strings.Contains(last.Content, "denied") {
+				t.Fatalf("expected a denial tool message, got %s: %s", last.Role, last.Content)
+			}
+		})
+	}
+}
+
+func TestAuthorizeUsesRegistryOverride(t *testing.T) {
+	tool := NewTool("function", "echo", "", nil)
+	registry := NewToolRegistry(tool)
+	registry.SetPolicy("echo", ToolAsk)
+	chat := NewChat(&Agent{}, nil)
+	err := chat.authorize(context.Background(), tool, registry, &ToolCall{Name: "echo"})
+	var denied *ToolDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected ToolDeniedError without an approver, got %v", err)
+	}
+	if err := chat.authorize(context.Background(), tool, nil, &ToolCall{Name: "echo"}); err != nil {
+		t.Fatalf("tool without override should be allowed: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that reads and writes JSON as a string such as "500ms" or "2m".
+// Plain numbers are accepted as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			if seconds, numErr := strconv.ParseFloat(v, 64); numErr == nil {
+				*d = Duration(seconds * float64(time.Second))
+				return nil
+			}
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+	return nil
+}
+
+// RetryPolicy controls how failed provider calls are retried.
+// Only errors for which IsRetryable is true are retried; zero fields fall back
+// to DefaultRetryPolicy. Set MaxAttempts to 1 to disable retries.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"maxAttempts,omitempty"`
+	InitialBackoff Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     Duration `json:"maxBackoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty"`
+	Jitter         float64  `json:"jitter,omitempty"` // fraction of the backoff randomised, 0-1
+	// OnRetry, if set, is called before waiting for a retry (1 for the first)
+	// with the delay and the error that caused it. Without it retries are
+	// reported on stderr.
+	OnRetry func(retry int, delay time.Duration, err error) `json:"-"`
+}
+
+// DefaultRetryPolicy is used by agents that do not configure their own.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: Duration(500 * time.Millisecond),
+	MaxBackoff:     Duration(10 * time.Second),
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// withDefaults fills unset fields from DefaultRetryPolicy.
+func (p *RetryPolicy) withDefaults() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if p == nil {
+		return policy
+	}
+	if p.MaxAttempts > 0 {
+		policy.MaxAttempts = p.MaxAttempts
+	}
+	if p.InitialBackoff > 0 {
+		policy.InitialBackoff = p.InitialBackoff
+	}
+	if p.MaxBackoff > 0 {
+		policy.MaxBackoff = p.MaxBackoff
+	}
+	if p.Multiplier > 0 {
+		policy.Multiplier = p.Multiplier
+	}
+	if p.Jitter > 0 {
+		policy.Jitter = math.Min(p.Jitter, 1)
+	}
+	policy.OnRetry = p.OnRetry
+	return policy
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry),
+// growing exponentially up to MaxBackoff with random jitter applied.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// Do runs fn until it succeeds, returns a non-retryable error, the attempts
+// run out or ctx ends. A server asking to wait longer than MaxBackoff ends it
+// too. The last error is returned.
+func (p *RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	policy := p.withDefaults()
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		delay := policy.Backoff(attempt)
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > delay {
+			if providerErr.RetryAfter > time.Duration(policy.MaxBackoff) {
+				return err
+			}
+			delay = providerErr.RetryAfter
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, delay, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Retrying in %s (attempt %d/%d): %v\n", delay.Round(time.Millisecond), attempt+1, policy.MaxAttempts, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryBackend retries the calls of the wrapped backend according to policy.
+type retryBackend struct {
+	backend Backend
+	policy  *RetryPolicy
+}
+
+func withRetry(backend Backend, policy *RetryPolicy) Backend {
+	return &retryBackend{backend: backend, policy: policy}
+}
+
+func (r *retryBackend) Chat(ctx context.Context, req *ChatRequest) (response *ChatResponse, err error) {
+	err = r.policy.Do(ctx, func() error {
+		response, err = r.backend.Chat(ctx, req)
+		return err
+	})
+	return response, err
+}
+
+func (r *retryBackend) Generate(ctx context.Context, req *GenerateRequest) (response *ChatResponse, err error) {
+	err = r.policy.Do(ctx, func() error {
+		response, err = r.backend.Generate(ctx, req)
+		return err
+	})
+	return response, err
+}
+
+func (r *retryBackend) Embed(ctx context.Context, model Model, content string) (embedding []float64, err error) {
+	err = r.policy.Do(ctx, func() error {
+		embedding, err = r.backend.Embed(ctx, model, content)
+		return err
+	})
+	return embedding, err
+}
+
+func (r *retryBackend) Tokenize(ctx context.Context, model Model, content string) (tokens []int, err error) {
+	err = r.policy.Do(ctx, func() error {
+		tokens, err = r.backend.Tokenize(ctx, model, content)
+		return err
+	})
+	return tokens, err
+}
+
+func (r *retryBackend) ListModels(ctx context.Context) (models []*ModelInfo, err error) {
+	err = r.policy.Do(ctx, func() error {
+		models, err = r.backend.ListModels(ctx)
+		return err
+	})
+	return models, err
+}
+
+func (r *retryBackend) ShowModel(ctx context.Context, name string) (model *ModelInfo, err error) {
+	err = r.policy.Do(ctx, func() error {
+		model, err = r.backend.ShowModel(ctx, name)
+		return err
+	})
+	return model, err
+}
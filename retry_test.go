@@ -0,0 +1,170 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: Duration(100 * time.Millisecond), MaxBackoff: Duration(time.Second), Multiplier: 2}
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 10: time.Second} {
+		if got := policy.Backoff(retry); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", retry, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(2); got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("Backoff(2) with jitter 0.5 = %s, want 100ms-300ms", got)
+		}
+	}
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	var policy *RetryPolicy
+	if got := policy.withDefaults(); got.MaxAttempts != DefaultRetryPolicy.MaxAttempts || got.Jitter != DefaultRetryPolicy.Jitter {
+		t.Fatalf("nil policy = %+v, want DefaultRetryPolicy", got)
+	}
+	got := (&RetryPolicy{MaxAttempts: 1, Jitter: 3}).withDefaults()
+	if got.MaxAttempts != 1 || got.Jitter != 1 || got.MaxBackoff != DefaultRetryPolicy.MaxBackoff {
+		t.Fatalf("unexpected policy %+v", got)
+	}
+}
+
+// retryable is a failure Do retries, asking to wait retryAfter.
+func retryable(retryAfter time.Duration) error {
+	return &ProviderError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Retryable: true, RetryAfter: retryAfter}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	fast := RetryPolicy{MaxAttempts: 3, InitialBackoff: Duration(time.Millisecond), MaxBackoff: Duration(50 * time.Millisecond)}
+	permanent := &ProviderError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+	tests := []struct {
+		name     string
+		failures []error // returned by the attempts before fn succeeds
+		calls    int
+		err      error
+		delays   []time.Duration
+	}{
+		{name: "success", calls: 1},
+		{name: "retries until success", failures: []error{retryable(0), retryable(0)}, calls: 3, delays: []time.Duration{time.Millisecond, 2 * time.Millisecond}},
+		{name: "attempts run out", failures: []error{retryable(0), retryable(0), retryable(0)}, calls: 3, err: retryable(0), delays: []time.Duration{time.Millisecond, 2 * time.Millisecond}},
+		{name: "not retryable", failures: []error{permanent}, calls: 1, err: permanent},
+		{name: "retry after", failures: []error{retryable(20 * time.Millisecond)}, calls: 2, delays: []time.Duration{20 * time.Millisecond}},
+		{name: "retry after too long", failures: []error{retryable(time.Hour)}, calls: 1, err: retryable(time.Hour)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := fast
+			var delays []time.Duration
+			policy.OnRetry = func(retry int, delay time.Duration, err error) {
+				if retry != len(delays)+1 {
+					t.Errorf("OnRetry got retry %d, want %d", retry, len(delays)+1)
+				}
+				delays = append(delays, delay)
+			}
+			calls := 0
+			started := time.Now()
+			err := policy.Do(context.Background(), func() error {
+				calls++
+				if calls <= len(test.failures) {
+					return test.failures[calls-1]
+				}
+				return nil
+			})
+			if calls != test.calls {
+				t.Fatalf("fn ran %d times, want %d", calls, test.calls)
+			}
+			if (err == nil) != (test.err == nil) || (err != nil && err.Error() != test.err.Error()) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if len(delays) != len(test.delays) {
+				t.Fatalf("waited %v, want %v", delays, test.delays)
+			}
+			for i, want := range test.delays {
+				// the default jitter of 0.2 applies
+				if delays[i] < want*8/10 || delays[i] > want*12/10 {
+					t.Fatalf("waited %v, want %v", delays, test.delays)
+				}
+			}
+			if elapsed := time.Since(started); elapsed > time.Second {
+				t.Fatalf("Do took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := &RetryPolicy{MaxAttempts: 5, InitialBackoff: Duration(time.Hour), MaxBackoff: Duration(time.Hour)}
+	policy.OnRetry = func(int, time.Duration, error) { cancel() }
+	calls := 0
+	err := policy.Do(ctx, func() error {
+		calls++
+		return retryable(0)
+	})
+	if calls != 1 || !IsRetryable(err) {
+		t.Fatalf("got %d calls and %v, want the first error once the context ends", calls, err)
+	}
+}
+
+func TestAgentRetriesProviderErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":"overloaded"}`, http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"done":    true,
+			"message": map[string]interface{}{"role": "assistant", "content": "ok"},
+		})
+	}))
+	defer server.Close()
+
+	agent := &Agent{
+		Name:     "Retrying",
+		Model:    Model{Name: "fake", ContextWindow: 4096},
+		Provider: &Provider{Type: "ollama", BaseUrl: server.URL},
+		Retry:    &RetryPolicy{InitialBackoff: Duration(time.Millisecond), OnRetry: func(int, time.Duration, error) {}},
+	}
+	response, err := NewChat(agent, nil).SendMessage("user", "hi", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || response.Message.Content != "ok" {
+		t.Fatalf("got %d attempts and %q", attempts, response.Message.Content)
+	}
+
+	agent.Retry = &RetryPolicy{MaxAttempts: 1}
+	attempts = 0
+	_, err = NewChat(agent, nil).SendMessage("user", "hi", false)
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != http.StatusServiceUnavailable || providerErr.Message != "overloaded" {
+		t.Fatalf("got %v, want the 503 as a ProviderError", err)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	for input, want := range map[string]time.Duration{`"500ms"`: 500 * time.Millisecond, `2`: 2 * time.Second, `"1.5"`: 1500 * time.Millisecond} {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err != nil || time.Duration(d) != want {
+			t.Errorf("%s decoded to %s, %v; want %s", input, time.Duration(d), err, want)
+		}
+	}
+	var d Duration
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error("an invalid duration was accepted")
+	}
+	if data, _ := json.Marshal(Duration(90 * time.Second)); string(data) != `"1m30s"` {
+		t.Errorf("marshalled to %s", data)
+	}
+}
@@ -0,0 +1,90 @@
+package goAgent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxIterations is the number of model calls a Run makes when RunOptions leaves it unset.
+const DefaultMaxIterations = 10
+
+var (
+	// ErrMaxIterations is returned by Run when the model still calls tools after the last allowed iteration.
+	ErrMaxIterations = errors.New("maximum iterations reached")
+	// ErrTokenBudget is returned by Run when the conversation used up its token budget.
+	ErrTokenBudget = errors.New("token budget exhausted")
+)
+
+// RunOptions limits an agentic Run.
+type RunOptions struct {
+	MaxIterations int           // model calls allowed; DefaultMaxIterations when zero
+	TokenBudget   int           // prompt plus completion tokens allowed across all calls; unlimited when zero
+	OnEvent       StreamHandler // when set, every model call is streamed to it
+	Tools         *ToolRegistry // when set, replaces the agent's tools for this run
+}
+
+// RunStep is one model call of a Run along with the tool results sent back for it.
+type RunStep struct {
+	Response    *ChatResponse
+	ToolResults []*Message
+}
+
+// RunResult is the history of a Run.
+type RunResult struct {
+	Steps    []*RunStep
+	Response *ChatResponse // the last reply of the model
+	Tokens   int           // prompt plus completion tokens used by all steps
+}
+
+// Run sends a message and lets the model work: every tool call in a reply is
+// executed, its result is appended as a tool message and the conversation is
+// sent again, until the model answers without calling tools.
+// When a limit in options is reached Run stops with ErrMaxIterations or
+// ErrTokenBudget. The steps taken so far are returned in every case.
+func (c *Chat) Run(ctx context.Context, role, content string, options *RunOptions) (*RunResult, error) {
+	if options == nil {
+		options = &RunOptions{}
+	}
+	maxIterations := options.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+	call := callOptions{stream: options.OnEvent != nil, handler: options.OnEvent, tools: options.Tools}
+
+	ctx = c.withRuntime(ctx)
+	defer c.begin(ctx, call)()
+
+	c.AddMessage(role, content)
+	result := &RunResult{}
+	for iteration := 1; ; iteration++ {
+		response, err := c.complete(ctx, call)
+		if err != nil {
+			return result, err
+		}
+		step := &RunStep{Response: response}
+		result.Steps = append(result.Steps, step)
+		result.Response = response
+		result.Tokens += response.PromptEvalCount + response.EvalCount
+
+		if len(response.Message.ToolCalls) == 0 {
+			return result, nil
+		}
+		step.ToolResults = c.RunTools(&response.Message)
+		if err = ctx.Err(); err != nil {
+			return result, err
+		}
+		if iteration >= maxIterations {
+			return result, fmt.Errorf("%w: %d", ErrMaxIterations, maxIterations)
+		}
+		if options.TokenBudget > 0 && result.Tokens >= options.TokenBudget {
+			return result, fmt.Errorf("%w: used %d of %d tokens", ErrTokenBudget, result.Tokens, options.TokenBudget)
+		}
+	}
+}
+
+// RunUserMessage runs the agent loop for a user message. See Run.
+func (c *Chat) RunUserMessage(ctx context.Context, content string, options *RunOptions) (*RunResult, error) {
+	content = "**User Prompt**:\n " + content
+	return c.Run(ctx, "user", content, options)
+}
@@ -0,0 +1,144 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// adder is a tool returning the sum of its integer arguments a and b.
+func adder() *Tool {
+	add := NewTool("function", "add", "adds two numbers", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		var arguments struct{ A, B int }
+		if err := inv.Bind(&arguments); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"sum": arguments.A + arguments.B}, nil
+	})
+	add.Function.Parameters = *NewToolParameters("object")
+	add.Function.Parameters.SetProperty("a", NewIntegerProperty(""), true)
+	add.Function.Parameters.SetProperty("b", NewIntegerProperty(""), true)
+	return add
+}
+
+// lastMessage returns the last message of a recorded chat request.
+func lastMessage(request map[string]interface{}) map[string]interface{} {
+	messages := request["messages"].([]interface{})
+	return messages[len(messages)-1].(map[string]interface{})
+}
+
+func TestRunSendsToolResultsBack(t *testing.T) {
+	fake := newFakeOllama(t, func(request map[string]interface{}) map[string]interface{} {
+		if lastMessage(request)["role"] == "tool" {
+			return map[string]interface{}{"role": "assistant", "content": "done"}
+		}
+		reply := callTool("add", map[string]interface{}{"a": 1, "b": 2})
+		named := callTool("add", map[string]interface{}{"a": "3", "b": 4})["tool_calls"].([]interface{})[0].(map[string]interface{})
+		named["id"] = "call-b"
+		reply["tool_calls"] = append(reply["tool_calls"].([]interface{}), named)
+		return reply
+	})
+	chat := NewChat(fake.agent(adder()), nil)
+
+	result, err := chat.Run(context.Background(), "user", "add things", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Steps) != 2 || result.Response != result.Steps[1].Response || result.Response.Message.Content != "done" {
+		t.Fatalf("got %d steps ending with %+v, want 2 ending with the answer", len(result.Steps), result.Response)
+	}
+	if len(result.Steps[1].ToolResults) != 0 {
+		t.Fatal("the answer without tool calls ran tools")
+	}
+
+	calls := result.Steps[0].Response.Message.ToolCalls
+	results := result.Steps[0].ToolResults
+	if len(calls) != 2 || len(results) != 2 || calls[0].ID == "" || calls[1].ID != "call-b" {
+		t.Fatalf("got calls %+v and results %+v", calls, results)
+	}
+	for i, want := range []float64{3, 7} {
+		if results[i].ToolCallID != calls[i].ID || results[i].ToolError {
+			t.Fatalf("result %d answers %q, want %q", i, results[i].ToolCallID, calls[i].ID)
+		}
+		var content struct{ Result struct{ Sum float64 } }
+		if err = json.Unmarshal([]byte(results[i].Content), &content); err != nil || content.Result.Sum != want {
+			t.Fatalf("result %d is %s, want the sum %v", i, results[i].Content, want)
+		}
+	}
+
+	// The second request replays the calls and answers each by its ID.
+	if len(fake.requests) != 2 {
+		t.Fatalf("the model was called %d times, want 2", len(fake.requests))
+	}
+	messages := fake.requests[1]["messages"].([]interface{})
+	var roles []string
+	for _, message := range messages {
+		roles = append(roles, message.(map[string]interface{})["role"].(string))
+	}
+	if len(roles) != 4 || roles[1] != "assistant" || roles[2] != "tool" || roles[3] != "tool" {
+		t.Fatalf("second request has roles %v", roles)
+	}
+	sent := messages[1].(map[string]interface{})["tool_calls"].([]interface{})
+	for i, call := range sent {
+		id := call.(map[string]interface{})["id"]
+		if answer := messages[2+i].(map[string]interface{})["tool_call_id"]; id != calls[i].ID || answer != id {
+			t.Fatalf("call %d was sent as %v and answered as %v, want %s", i, id, answer, calls[i].ID)
+		}
+	}
+	if len(chat.Messages) != 5 {
+		t.Fatalf("the chat holds %d messages, want 5", len(chat.Messages))
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		options RunOptions
+		tokens  int
+		err     error
+		steps   int
+	}{
+		{name: "iterations", options: RunOptions{MaxIterations: 3}, err: ErrMaxIterations, steps: 3},
+		{name: "default iterations", err: ErrMaxIterations, steps: DefaultMaxIterations},
+		{name: "token budget", options: RunOptions{TokenBudget: 100}, tokens: 20, err: ErrTokenBudget, steps: 3},
+		{name: "budget left", options: RunOptions{MaxIterations: 2, TokenBudget: 100}, tokens: 20, err: ErrMaxIterations, steps: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+				return callTool("add", map[string]interface{}{"a": 1, "b": 1})
+			})
+			fake.tokens = test.tokens
+			agent := fake.agent()
+			test.options.Tools = NewToolRegistry(adder()) // the agent itself has none
+
+			result, err := NewChat(agent, nil).Run(context.Background(), "user", "loop", &test.options)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if len(result.Steps) != test.steps || len(fake.requests) != test.steps {
+				t.Fatalf("got %d steps and %d requests, want %d", len(result.Steps), len(fake.requests), test.steps)
+			}
+			if result.Tokens != 2*test.tokens*test.steps {
+				t.Fatalf("counted %d tokens, want %d", result.Tokens, 2*test.tokens*test.steps)
+			}
+			for i, step := range result.Steps {
+				if len(step.ToolResults) != 1 || step.ToolResults[0].ToolError {
+					t.Fatalf("step %d has results %+v, want the tool run", i, step.ToolResults)
+				}
+			}
+		})
+	}
+}
+
+func TestRunReturnsModelErrors(t *testing.T) {
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} { return nil })
+	fake.Close()
+	agent := fake.agent()
+	agent.Retry = &RetryPolicy{MaxAttempts: 1}
+	result, err := NewChat(agent, nil).Run(context.Background(), "user", "hi", nil)
+	if err == nil || result == nil || len(result.Steps) != 0 {
+		t.Fatalf("got %+v and %v, want the error and no steps", result, err)
+	}
+}
@@ -0,0 +1,160 @@
+package goAgent
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Runtime holds what an agent setup shares: the mesh its agents come from,
+// the HTTP client provider calls go through and a cache. Independent setups in
+// one process, such as tests next to production code or one per tenant, each
+// use their own Runtime. Chats carry it in Chat.Runtime; tool handlers and
+// searches find it with ToolInvocation.Runtime or RuntimeFrom.
+type Runtime struct {
+	Mesh *AgentMesh // when nil, the package-level agents are used
+	// Names in Mesh of the agents with a role; empty names default to
+	// "Embedder", "Summarizer" and "Planner".
+	EmbedderName   string
+	SummarizerName string
+	PlannerName    string
+	// SummaryPorts adds a copy of the summarizer on each of these ports of its
+	// provider, so search results are summarized in parallel.
+	SummaryPorts []string
+	// MinRelevancy is the score (0-100) a search result needs to be kept;
+	// 0 leaves it to the search tool.
+	MinRelevancy float64
+	HTTPClient   *http.Client // nil uses a client with a 10 minute timeout
+	Cache        Cache
+}
+
+// DefaultRuntime is used wherever no runtime is given. It has no mesh and
+// serves the package-level EmbeddingAgent, SummaryAgent and PlannerAgent,
+// which are kept for it and for code written before Runtime.
+var DefaultRuntime = &Runtime{SummaryPorts: []string{"11436"}}
+
+// NewRuntime returns a runtime serving the agents of mesh.
+func NewRuntime(mesh *AgentMesh) *Runtime {
+	return &Runtime{Mesh: mesh}
+}
+
+type runtimeKey struct{}
+
+// WithRuntime returns a copy of ctx carrying runtime.
+func WithRuntime(ctx context.Context, runtime *Runtime) context.Context {
+	return context.WithValue(ctx, runtimeKey{}, runtime)
+}
+
+// RuntimeFrom returns the runtime carried by ctx, or DefaultRuntime.
+func RuntimeFrom(ctx context.Context) *Runtime {
+	if ctx != nil {
+		if runtime, ok := ctx.Value(runtimeKey{}).(*Runtime); ok && runtime != nil {
+			return runtime
+		}
+	}
+	return DefaultRuntime
+}
+
+// Agent returns the current definition of the agent called name in the
+// runtime's mesh, or nil.
+func (r *Runtime) Agent(name string) *Agent {
+	if r.Mesh == nil {
+		return nil
+	}
+	agent, _ := r.Mesh.Agent(name)
+	return agent
+}
+
+// EmbeddingAgent returns the agent used for embeddings.
+func (r *Runtime) EmbeddingAgent() *Agent {
+	if r.Mesh == nil {
+		return EmbeddingAgent
+	}
+	return r.Agent(orDefault(r.EmbedderName, "Embedder"))
+}
+
+// SummaryAgent returns the agent used for summaries.
+func (r *Runtime) SummaryAgent() *Agent {
+	if r.Mesh == nil {
+		return SummaryAgent
+	}
+	return r.Agent(orDefault(r.SummarizerName, "Summarizer"))
+}
+
+// PlannerAgent returns the agent used for planning.
+func (r *Runtime) PlannerAgent() *Agent {
+	if r.Mesh == nil {
+		return PlannerAgent
+	}
+	return r.Agent(orDefault(r.PlannerName, "Planner"))
+}
+
+// SummaryAgents returns the summarizer pool: the summary agent followed by a
+// copy of it for each of SummaryPorts. It is empty without a summary agent.
+func (r *Runtime) SummaryAgents() []*Agent {
+	summarizer := r.SummaryAgent()
+	if summarizer == nil {
+		return nil
+	}
+	agents := []*Agent{summarizer}
+	if summarizer.Provider == nil {
+		return agents
+	}
+	for _, port := range r.SummaryPorts {
+		agents = append(agents, summarizer.WithPort(port))
+	}
+	return agents
+}
+
+// Client returns the HTTP client provider calls are made with.
+func (r *Runtime) Client() *http.Client {
+	if r.HTTPClient != nil {
+		return r.HTTPClient
+	}
+	return client
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// Cache is a concurrency-safe key-value store. The zero value is ready to use.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]interface{}
+}
+
+// Get returns the value stored under key.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.entries[key]
+	return value, ok
+}
+
+// Set stores value under key.
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]interface{})
+	}
+	c.entries[key] = value
+}
+
+// Delete removes key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Clear removes every entry.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
@@ -0,0 +1,87 @@
+package goAgent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRuntimeFrom(t *testing.T) {
+	if RuntimeFrom(context.Background()) != DefaultRuntime {
+		t.Fatal("a context without a runtime should give DefaultRuntime")
+	}
+	runtime := NewRuntime(NewStaticMesh(nil))
+	if RuntimeFrom(WithRuntime(context.Background(), runtime)) != runtime {
+		t.Fatal("the runtime carried by the context was not returned")
+	}
+	if RuntimeFrom(WithRuntime(context.Background(), nil)) != DefaultRuntime {
+		t.Fatal("a nil runtime should fall back to DefaultRuntime")
+	}
+}
+
+func TestDefaultRuntimeUsesPackageAgents(t *testing.T) {
+	embedder, summarizer, planner := EmbeddingAgent, SummaryAgent, PlannerAgent
+	t.Cleanup(func() { EmbeddingAgent, SummaryAgent, PlannerAgent = embedder, summarizer, planner })
+	EmbeddingAgent = &Agent{Name: "Embedder"}
+	SummaryAgent = &Agent{Name: "Summarizer", Provider: &Provider{BaseUrl: "http://localhost"}}
+	PlannerAgent = &Agent{Name: "Planner"}
+
+	runtime := &Runtime{SummaryPorts: []string{"11436"}}
+	if runtime.EmbeddingAgent() != EmbeddingAgent || runtime.SummaryAgent() != SummaryAgent || runtime.PlannerAgent() != PlannerAgent {
+		t.Fatal("a runtime without a mesh should serve the package-level agents")
+	}
+	pool := runtime.SummaryAgents()
+	if len(pool) != 2 || pool[0] != SummaryAgent || pool[1].Provider.Port != "11436" || SummaryAgent.Provider.Port != "" {
+		t.Fatalf("unexpected summarizer pool %+v", pool)
+	}
+}
+
+func TestRuntimeServesMeshAgents(t *testing.T) {
+	mesh := NewStaticMesh(map[string]*Agent{
+		"Embedder":   {Name: "Embedder"},
+		"Summarizer": {Name: "Summarizer"},
+		"Planner":    {Name: "Planner"},
+		"Writer":     {Name: "Writer"},
+	})
+	runtime := NewRuntime(mesh)
+	if runtime.EmbeddingAgent().Name != "Embedder" || runtime.SummaryAgent().Name != "Summarizer" || runtime.PlannerAgent().Name != "Planner" {
+		t.Fatal("the mesh's agents were not served under their default names")
+	}
+	runtime.PlannerName = "Writer"
+	if runtime.PlannerAgent().Name != "Writer" {
+		t.Fatal("PlannerName was ignored")
+	}
+	runtime.SummarizerName = "Missing"
+	if runtime.SummaryAgent() != nil || runtime.SummaryAgents() != nil {
+		t.Fatal("a missing summarizer should give no agents")
+	}
+}
+
+func TestToolsSeeChatRuntime(t *testing.T) {
+	var seen []*Runtime
+	probe := NewTool("function", "probe", "reports the runtime", func(inv *ToolInvocation) (map[string]interface{}, error) {
+		seen = append(seen, inv.Runtime())
+		return map[string]interface{}{}, nil
+	})
+	fake := newFakeOllama(t, func(request map[string]interface{}) map[string]interface{} {
+		messages := request["messages"].([]interface{})
+		if messages[len(messages)-1].(map[string]interface{})["role"] == "tool" {
+			return map[string]interface{}{"role": "assistant", "content": "done"}
+		}
+		return callTool("probe", map[string]interface{}{})
+	})
+
+	runtime := NewRuntime(NewStaticMesh(nil))
+	chat := NewChat(fake.agent(probe), nil)
+	chat.Runtime = runtime
+	if _, err := chat.Run(context.Background(), "user", "go", nil); err != nil {
+		t.Fatal(err)
+	}
+	other := NewRuntime(NewStaticMesh(nil))
+	chat = NewChat(fake.agent(probe), nil)
+	if _, err := chat.Run(WithRuntime(context.Background(), other), "user", "go", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != runtime || seen[1] != other {
+		t.Fatalf("tools saw runtimes %v, want the chat's and then the context's", seen)
+	}
+}
@@ -0,0 +1,125 @@
+package goAgent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MarshalCanonical encodes v the way the Save functions write it: indented with
+// two spaces, keys of maps sorted, HTML characters left unescaped and a
+// trailing newline, so saved files diff cleanly.
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// SaveJSON writes v to path in canonical form. The file is replaced atomically,
+// so a failed save leaves the previous contents in place.
+func SaveJSON(path string, v interface{}) error {
+	data, err := MarshalCanonical(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	defer os.Remove(temp.Name()) // no-op once renamed
+	mode := os.FileMode(0o644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = temp.Chmod(mode); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if _, err = temp.Write(data); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err = temp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err = os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
+
+// SaveAgents writes agents to path in the format LoadAgents reads. Settings
+// loaded from ${VAR} templates are written as the template, not the value.
+func SaveAgents(path string, agents map[string]*Agent) error {
+	saved := make(map[string]*Agent, len(agents))
+	for name, agent := range agents {
+		if agent != nil {
+			agent = agent.withEnvTemplates()
+		}
+		saved[name] = agent
+	}
+	return SaveJSON(path, saved)
+}
+
+// SaveAgentMesh writes the agents of the mesh's current snapshot to path in the
+// format LoadAgents reads.
+func SaveAgentMesh(path string, mesh *AgentMesh) error {
+	return SaveAgents(path, mesh.Snapshot().Agents)
+}
+
+// SaveTool writes tool to path in the format LoadTool reads. Handlers are code
+// and are not saved; InitTool attaches them again on load.
+func SaveTool(path string, tool *Tool) error {
+	return SaveJSON(path, tool)
+}
+
+// SaveTools writes the registry's tools to path as a JSON array sorted by name,
+// in the format LoadTools reads.
+func SaveTools(path string, registry *ToolRegistry) error {
+	return SaveJSON(path, registry.sortedTools())
+}
+
+// LoadTools reads a JSON array of tools written by SaveTools into registry.
+// A tool already registered under the same name keeps its handler, so
+// definitions can be edited as JSON while the code stays in Go.
+func LoadTools(file *os.File, registry *ToolRegistry) error {
+	var tools []*Tool
+	if err := BindJSON(file, &tools); err != nil {
+		return fmt.Errorf("failed to load tools from %s: %w", file.Name(), err)
+	}
+	for _, tool := range tools {
+		if existing, ok := registry.GetTool(tool.Function.Name); ok && tool.Function.FunctionCall == nil {
+			tool.Function.FunctionCall = existing.Function.FunctionCall
+		}
+		registry.RegisterTool(tool)
+	}
+	return nil
+}
+
+// ExportTools writes each of the registry's tools to dir as <name>.json, in the
+// format LoadTool and InitTool read.
+func (tr *ToolRegistry) ExportTools(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, tool := range tr.sortedTools() {
+		if err := SaveTool(filepath.Join(dir, tool.Function.Name+".json"), tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tr *ToolRegistry) sortedTools() []*Tool {
+	tools := tr.GetTools()
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Function.Name < tools[j].Function.Name })
+	return tools
+}
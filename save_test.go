@@ -0,0 +1,123 @@
+package goAgent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// roundTripTools are tools covering the shapes of parameters: none at all,
+// built with the helpers, and nested objects and arrays.
+func roundTripTools() []*Tool {
+	bare := NewTool("function", "bare", "takes no arguments", nil)
+
+	empty := NewTool("function", "empty", "has an empty schema", nil)
+	empty.Function.Parameters = *NewToolParameters("object")
+
+	search := NewTool("function", "search", "searches the web", nil)
+	search.Function.Parameters = *NewToolParameters("object")
+	search.Function.Parameters.AddProperty("query", "string", "what to look for", nil, true)
+	search.Function.Parameters.SetProperty("pages", NewToolParameterProperty("integer", "pages to read", nil, false).WithRange(1, 5), false)
+	search.Function.Parameters.SetProperty("filters", NewToolParameterProperty("object", "narrows the search", nil, false).
+		WithProperty("site", NewToolParameterProperty("string", "", nil, false), true).
+		WithProperty("tags", &ToolParameterProperty{Type: "array", Items: NewToolParameterProperty("string", "", []string{"news", "papers"}, false)}, false), false)
+	search.AddExamples("search for go generics")
+	search.AddConstraints("at most 3 queries")
+	search.Timeout = Duration(30e9)
+	search.Policy = ToolAsk
+	return []*Tool{bare, empty, search}
+}
+
+func TestSaveToolRoundTrip(t *testing.T) {
+	for _, tool := range roundTripTools() {
+		path := filepath.Join(t.TempDir(), tool.Function.Name+".json")
+		if err := SaveTool(path, tool); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var loaded Tool
+		err = LoadTool(file, &loaded)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&loaded, tool) {
+			t.Errorf("%s: load(save(x)) != x\n got: %#v\nwant: %#v", tool.Function.Name, loaded.Function.Parameters, tool.Function.Parameters)
+		}
+	}
+}
+
+func TestSaveToolsRoundTrip(t *testing.T) {
+	registry := NewToolRegistry(roundTripTools()...)
+	path := filepath.Join(t.TempDir(), "tools.json")
+	if err := SaveTools(path, registry); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	loaded := NewToolRegistry()
+	if err = LoadTools(file, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.GetToolMap(), registry.GetToolMap()) {
+		t.Error("load(save(registry)) != registry")
+	}
+}
+
+func roundTripAgents() map[string]*Agent {
+	temperature := 0.2
+	registry := NewToolRegistry(roundTripTools()...)
+	registry.SetPolicy("search", ToolDeny)
+	return map[string]*Agent{
+		"Planner": {
+			Name:        "Planner",
+			Model:       Model{Name: "qwen3", ContextWindow: 8192, Reasoning: true, Options: &ModelOptions{Temperature: &temperature}},
+			Description: "plans",
+			Provider:    &Provider{Type: "ollama", BaseUrl: "http://localhost", Port: "11434", ChatEndpoint: "/api/chat"},
+			Tools:       registry,
+			Retry:       &RetryPolicy{MaxAttempts: 3},
+		},
+		"Embedder": {
+			Name:     "Embedder",
+			Model:    Model{Name: "nomic", ContextWindow: 2048, EmbeddingLength: 768},
+			Provider: &Provider{Type: "ollama", BaseUrl: "http://localhost", EmbeddingEndpoint: "/api/embeddings"},
+		},
+	}
+}
+
+func TestSaveAgentsRoundTrip(t *testing.T) {
+	agents := roundTripAgents()
+	path := filepath.Join(t.TempDir(), "agents.json")
+	if err := SaveAgents(path, agents); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAgentsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, agents) {
+		t.Errorf("load(save(agents)) != agents\n got: %#v\nwant: %#v", loaded, agents)
+	}
+}
+
+func TestSaveAgentMeshRoundTrip(t *testing.T) {
+	mesh := NewStaticMesh(roundTripAgents())
+	path := filepath.Join(t.TempDir(), "agents.json")
+	if err := SaveAgentMesh(path, mesh); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewAgentMesh(path)
+	if err := reloaded.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded.Snapshot().Agents, mesh.Snapshot().Agents) {
+		t.Error("load(save(mesh)) != mesh")
+	}
+}
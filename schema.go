@@ -0,0 +1,360 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaFor derives a JSON Schema describing T.
+// Struct fields are named after their json tag; fields without omitempty are
+// required unless tagged `required:"false"` (and `required:"true"` forces it).
+// The optional `description:"..."` and `enum:"a,b,c"` tags are copied into the
+// field's schema; enum values are parsed as the field's type, so `enum:"1,2,3"`
+// on an int allows the numbers 1, 2 and 3. []byte is a string, as
+// encoding/json writes it in base64.
+func SchemaFor[T any]() map[string]interface{} {
+	return schemaOf(reflect.TypeOf((*T)(nil)).Elem(), map[reflect.Type]bool{})
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"} // recursive type, stop here
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]interface{}{}
+		required := make([]string, 0)
+		addStructFields(t, seen, properties, &required)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{} // interfaces and anything else accept any value
+}
+
+// addStructFields adds the schemas of t's exported fields, flattening embedded structs
+// the way encoding/json does.
+func addStructFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitEmpty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addStructFields(fieldType, seen, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOf(field.Type, seen)
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property["enum"] = enumValues(field.Type, enum)
+		}
+		properties[name] = property
+		isRequired := !omitEmpty
+		if tag, ok := field.Tag.Lookup("required"); ok {
+			isRequired = tag == "true"
+		}
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// enumValues parses the values of an enum tag as the JSON values of a field of
+// type t. Values that do not parse are kept as strings.
+func enumValues(t reflect.Type, tag string) []interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	parts := strings.Split(tag, ",")
+	values := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		values = append(values, enumValue(t.Kind(), part))
+	}
+	return values
+}
+
+func enumValue(kind reflect.Kind, text string) interface{} {
+	trimmed := strings.TrimSpace(text)
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return number
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, err := strconv.ParseUint(trimmed, 10, 64); err == nil {
+			return number
+		}
+	case reflect.Float32, reflect.Float64:
+		if number, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return number
+		}
+	case reflect.Bool:
+		if boolean, err := strconv.ParseBool(trimmed); err == nil {
+			return boolean
+		}
+	}
+	return text
+}
+
+// jsonFieldName returns the name a field is encoded under, whether it is omitempty,
+// and whether encoding/json skips it altogether.
+func jsonFieldName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		if option == "omitempty" || option == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+// ValidateSchema checks a decoded JSON value against schema and returns an error
+// naming the first offending path. It understands the subset of JSON Schema
+// produced by SchemaFor: type, properties, required, additionalProperties,
+// items and enum.
+func ValidateSchema(value interface{}, schema map[string]interface{}) error {
+	return validateSchema("$", value, schema)
+}
+
+func validateSchema(path string, value interface{}, schema map[string]interface{}) error {
+	if Type, ok := schema["type"].(string); ok {
+		if !schemaTypeMatches(Type, value) {
+			return fmt.Errorf("%s: expected %s, got %s", path, Type, jsonTypeName(value))
+		}
+	}
+	if enum := schemaValues(schema["enum"]); len(enum) > 0 && !enumContains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %s", path, value, enumList(enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propertyPath := path + "." + key
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				if err := validateSchema(propertyPath, v[key], property); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s: unknown field", propertyPath)
+				}
+			case map[string]interface{}:
+				if err := validateSchema(propertyPath, v[key], additional); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypeMatches(Type string, value interface{}) bool {
+	switch Type {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaValues reads a list of JSON values from a schema, accepting both the
+// []interface{} written by SchemaFor or decoded from JSON and a []string.
+func schemaValues(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		if v == nil {
+			return nil
+		}
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values
+	}
+	return nil
+}
+
+// enumContains reports whether value is one of enum. Numbers are compared by
+// value whatever their Go type; a string option also matches a number or
+// boolean written the same way, as older tool files list every enum as strings.
+func enumContains(enum []interface{}, value interface{}) bool {
+	number, isNumber := jsonNumber(value)
+	for _, option := range enum {
+		if optionNumber, ok := jsonNumber(option); ok && isNumber {
+			if optionNumber == number {
+				return true
+			}
+			continue
+		}
+		if text, ok := option.(string); ok {
+			if _, isString := value.(string); !isString && value != nil && text == fmt.Sprint(value) {
+				return true
+			}
+		}
+		if reflect.DeepEqual(option, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Float(), true
+	}
+	return 0, false
+}
+
+// enumList formats the options of an enum for error messages.
+func enumList(enum []interface{}) string {
+	options := make([]string, len(enum))
+	for i, option := range enum {
+		options[i] = fmt.Sprint(option)
+	}
+	return strings.Join(options, ", ")
+}
+
+// schemaStrings reads a string list from a schema, accepting both the []string
+// written by SchemaFor and the []interface{} produced by decoding JSON.
+func schemaStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type askLevel struct {
+	Level int     `json:"level" enum:"1,2,3"`
+	Ratio float64 `json:"ratio,omitempty" enum:"0.5,1.5"`
+	Mode  string  `json:"mode,omitempty" enum:"fast,slow"`
+	Data  []byte  `json:"data,omitempty"`
+}
+
+func TestSchemaForEnumsAndBytes(t *testing.T) {
+	schema := SchemaFor[askLevel]()
+	properties := schema["properties"].(map[string]interface{})
+	if got := properties["level"].(map[string]interface{})["enum"]; !reflect.DeepEqual(got, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Errorf("level enum = %#v", got)
+	}
+	if got := properties["mode"].(map[string]interface{})["enum"]; !reflect.DeepEqual(got, []interface{}{"fast", "slow"}) {
+		t.Errorf("mode enum = %#v", got)
+	}
+	if got := properties["data"]; !reflect.DeepEqual(got, map[string]interface{}{"type": "string"}) {
+		t.Errorf("data schema = %#v", got)
+	}
+}
+
+func TestValidateSchemaEnumsAndBytes(t *testing.T) {
+	schema := SchemaFor[askLevel]()
+	valid, err := json.Marshal(askLevel{Level: 2, Ratio: 1.5, Mode: "slow", Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bindStructured(string(valid), schema, &askLevel{}); err != nil {
+		t.Fatalf("valid reply rejected: %v", err)
+	}
+
+	invalid := map[string]string{
+		`{"level": 4}`:                  "$.level: 4 is not one of 1, 2, 3",
+		`{"level": "2"}`:                "$.level: expected integer, got string",
+		`{"level": 1, "mode": "other"}`: "$.mode: other is not one of fast, slow",
+		`{"level": 1, "data": [1, 2]}`:  "$.data: expected string, got array",
+	}
+	for reply, want := range invalid {
+		err := bindStructured(reply, schema, &askLevel{})
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %s", reply, err, want)
+		}
+	}
+}
+
+func TestAskAcceptsIntEnum(t *testing.T) {
+	fake := newFakeOllama(t, func(map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"role": "assistant", "content": `{"level": 2, "data": "aGVsbG8="}`}
+	})
+	result, err := Ask[askLevel](NewChat(fake.agent(), nil), "how hard?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Level != 2 || string(result.Data) != "hello" {
+		t.Fatalf("got %+v", result)
+	}
+	format, _ := fake.requests[0]["format"].(map[string]interface{})
+	level := format["properties"].(map[string]interface{})["level"].(map[string]interface{})
+	if enum := level["enum"].([]interface{}); len(enum) != 3 || enum[0] != float64(1) {
+		t.Fatalf("enum sent as %#v, want numbers", enum)
+	}
+}
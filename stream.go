@@ -0,0 +1,240 @@
+package goAgent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamEventType identifies what a StreamEvent carries.
+type StreamEventType string
+
+const (
+	StreamContent  StreamEventType = "content"   // a piece of the answer
+	StreamThinking StreamEventType = "thinking"  // a piece of the model's reasoning
+	StreamToolCall StreamEventType = "tool_call" // a complete tool call
+	StreamDone     StreamEventType = "done"      // the assembled final response
+)
+
+// StreamEvent is a single incremental update delivered while a response streams in.
+type StreamEvent struct {
+	Type     StreamEventType
+	Delta    string
+	ToolCall *ToolCall
+	Response *ChatResponse
+}
+
+// StreamHandler receives stream events in the order they arrive.
+type StreamHandler func(event *StreamEvent)
+
+// emit delivers an event to the handler if one is set.
+func (h StreamHandler) emit(event *StreamEvent) {
+	if h != nil {
+		h(event)
+	}
+}
+
+// SendMessageStream sends a message to the agent, streaming the reply to handler as it is generated.
+// The assembled response is returned once the model is done, just like SendMessage.
+func (c *Chat) SendMessageStream(role, content string, handler StreamHandler) (*ChatResponse, error) {
+	return c.sendMessage(context.Background(), role, content, callOptions{stream: true, handler: handler})
+}
+
+// SendMessageStreamContext is SendMessageStream bound to ctx.
+func (c *Chat) SendMessageStreamContext(ctx context.Context, role, content string, handler StreamHandler) (*ChatResponse, error) {
+	return c.sendMessage(ctx, role, content, callOptions{stream: true, handler: handler})
+}
+
+// SendUserMessageStream sends a user message to the agent, streaming the reply to handler.
+func (c *Chat) SendUserMessageStream(content string, handler StreamHandler) (*ChatResponse, error) {
+	content = "**User Prompt**:\n " + content
+	return c.SendMessageStream("user", content, handler)
+}
+
+// SendUserMessageStreamContext is SendUserMessageStream bound to ctx.
+func (c *Chat) SendUserMessageStreamContext(ctx context.Context, content string, handler StreamHandler) (*ChatResponse, error) {
+	content = "**User Prompt**:\n " + content
+	return c.SendMessageStreamContext(ctx, "user", content, handler)
+}
+
+// readNDJSON calls fn for every non-empty line of a newline-delimited JSON stream.
+func readNDJSON(body io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading stream: %w", err)
+		}
+	}
+}
+
+// readSSE calls fn with the data of every server-sent event until the stream
+// ends or the OpenAI style "[DONE]" sentinel arrives.
+func readSSE(body io.Reader, fn func(event string, data []byte) error) error {
+	reader := bufio.NewReader(body)
+	var event string
+	var data bytes.Buffer
+	dispatch := func() error {
+		defer func() {
+			event = ""
+			data.Reset()
+		}()
+		if data.Len() == 0 {
+			return nil
+		}
+		if bytes.Equal(data.Bytes(), []byte("[DONE]")) {
+			return io.EOF
+		}
+		return fn(event, data.Bytes())
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case trimmed == "":
+			if dispatchErr := dispatch(); dispatchErr != nil {
+				if dispatchErr == io.EOF {
+					return nil
+				}
+				return dispatchErr
+			}
+		case strings.HasPrefix(trimmed, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(trimmed, "event:"))
+		case strings.HasPrefix(trimmed, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(trimmed, "data:"), " "))
+		}
+		if err == io.EOF {
+			if dispatchErr := dispatch(); dispatchErr != nil && dispatchErr != io.EOF {
+				return dispatchErr
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading stream: %w", err)
+		}
+	}
+}
+
+const (
+	thinkOpenTag     = "<think>"
+	thinkCloseTag    = "</think>"
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+)
+
+// tagSplitter routes streamed content into content and thinking deltas for
+// models that inline <think> blocks, and hides inline <tool_call> blocks,
+// which are parsed from the assembled response instead.
+type tagSplitter struct {
+	handler StreamHandler
+	pending string
+	inThink bool
+	inTool  bool
+}
+
+func newTagSplitter(handler StreamHandler) *tagSplitter {
+	return &tagSplitter{handler: handler}
+}
+
+// feed processes the next content delta.
+func (s *tagSplitter) feed(delta string) {
+	text := s.pending + delta
+	s.pending = ""
+	for text != "" {
+		switch {
+		case s.inThink:
+			if end := strings.Index(text, thinkCloseTag); end >= 0 {
+				s.send(StreamThinking, text[:end])
+				text = text[end+len(thinkCloseTag):]
+				s.inThink = false
+				continue
+			}
+			text = s.hold(text, StreamThinking, thinkCloseTag)
+		case s.inTool:
+			if end := strings.Index(text, toolCallCloseTag); end >= 0 {
+				text = text[end+len(toolCallCloseTag):]
+				s.inTool = false
+				continue
+			}
+			keep := partialTagSuffix(text, toolCallCloseTag)
+			s.pending = text[len(text)-keep:]
+			text = ""
+		default:
+			think := strings.Index(text, thinkOpenTag)
+			tool := strings.Index(text, toolCallOpenTag)
+			if think >= 0 && (tool < 0 || think < tool) {
+				s.send(StreamContent, text[:think])
+				text = text[think+len(thinkOpenTag):]
+				s.inThink = true
+				continue
+			}
+			if tool >= 0 {
+				s.send(StreamContent, text[:tool])
+				text = text[tool+len(toolCallOpenTag):]
+				s.inTool = true
+				continue
+			}
+			text = s.hold(text, StreamContent, thinkOpenTag, toolCallOpenTag)
+		}
+	}
+}
+
+// flush emits whatever text is still held back waiting for a tag to complete.
+func (s *tagSplitter) flush() {
+	if s.pending == "" || s.inTool {
+		s.pending = ""
+		return
+	}
+	if s.inThink {
+		s.send(StreamThinking, s.pending)
+	} else {
+		s.send(StreamContent, s.pending)
+	}
+	s.pending = ""
+}
+
+// hold emits text except for a trailing partial tag, which is kept for the next delta.
+func (s *tagSplitter) hold(text string, eventType StreamEventType, tags ...string) string {
+	keep := partialTagSuffix(text, tags...)
+	s.send(eventType, text[:len(text)-keep])
+	s.pending = text[len(text)-keep:]
+	return ""
+}
+
+func (s *tagSplitter) send(eventType StreamEventType, delta string) {
+	if delta == "" {
+		return
+	}
+	s.handler.emit(&StreamEvent{Type: eventType, Delta: delta})
+}
+
+// partialTagSuffix returns the length of the longest suffix of text that is a
+// proper prefix of one of the tags.
+func partialTagSuffix(text string, tags ...string) int {
+	longest := 0
+	for _, tag := range tags {
+		for n := len(tag) - 1; n > longest; n-- {
+			if strings.HasSuffix(text, tag[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
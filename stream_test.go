@@ -0,0 +1,190 @@
+package goAgent
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadNDJSON(t *testing.T) {
+	var lines []string
+	err := readNDJSON(strings.NewReader("{\"a\":1}\n\n  \n{\"b\":2}\r\n{\"c\":3}"), func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil || strings.Join(lines, " ") != `{"a":1} {"b":2} {"c":3}` {
+		t.Fatalf("got %q and %v", lines, err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = readNDJSON(strings.NewReader("1\n2\n3\n"), func([]byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("got %v after %d lines, want the callback's error after 1", err, calls)
+	}
+
+	broken := iotest.TimeoutReader(strings.NewReader(strings.Repeat("x", 8192)))
+	if err = readNDJSON(broken, func([]byte) error { return nil }); err == nil || !strings.Contains(err.Error(), "error reading stream") {
+		t.Fatalf("got %v, want the read error", err)
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string // event:data pairs
+	}{
+		{name: "events", stream: "event: a\ndata: 1\n\nevent: b\ndata: 2\n\n", want: "a:1 b:2"},
+		{name: "no event names", stream: "data: 1\n\ndata:2\n\n", want: ":1 :2"},
+		{name: "multi-line data", stream: "data: one\ndata: two\n\n", want: ":one\ntwo"},
+		{name: "crlf", stream: "event: a\r\ndata: 1\r\n\r\n", want: "a:1"},
+		{name: "comments and ids", stream: ": keep-alive\nid: 7\nretry: 10\ndata: 1\n\n", want: ":1"},
+		{name: "done", stream: "data: 1\n\ndata: [DONE]\n\ndata: 2\n\n", want: ":1"},
+		{name: "no trailing blank line", stream: "data: 1\n\ndata: 2", want: ":1 :2"},
+		{name: "empty events", stream: "\n\nevent: ping\n\ndata: 1\n\n", want: ":1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []string
+			err := readSSE(iotest.OneByteReader(strings.NewReader(test.stream)), func(event string, data []byte) error {
+				events = append(events, event+":"+string(data))
+				return nil
+			})
+			if err != nil || strings.Join(events, " ") != test.want {
+				t.Fatalf("got %q and %v, want %q", events, err, test.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	if err := readSSE(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(string, []byte) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("got %v, want the callback's error", err)
+	}
+}
+
+// splitEvents feeds deltas to a tagSplitter and returns what it emitted, with
+// consecutive deltas of the same type joined, as "type:text" pairs. Empty
+// deltas show up as "empty".
+func splitEvents(deltas ...string) []string {
+	var events []string
+	var last StreamEventType
+	splitter := newTagSplitter(func(event *StreamEvent) {
+		switch {
+		case event.Delta == "":
+			events = append(events, "empty")
+		case len(events) > 0 && event.Type == last:
+			events[len(events)-1] += event.Delta
+		default:
+			events = append(events, string(event.Type)+":"+event.Delta)
+		}
+		last = event.Type
+	})
+	for _, delta := range deltas {
+		splitter.feed(delta)
+	}
+	splitter.flush()
+	return events
+}
+
+func TestTagSplitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   []string
+	}{
+		{name: "plain", deltas: []string{"Hello", " world"}, want: []string{"content:Hello world"}},
+		{name: "think", deltas: []string{"<think>hmm</think>Hi"}, want: []string{"thinking:hmm", "content:Hi"}},
+		{name: "think split in tags", deltas: []string{"<th", "ink>hm", "m</th", "ink>", "Hi"}, want: []string{"thinking:hmm", "content:Hi"}},
+		{name: "one byte at a time", deltas: strings.Split("a<think>b</think>c", ""), want: []string{"content:a", "thinking:b", "content:c"}},
+		{name: "tool call hidden", deltas: []string{"Let me check.<tool", "_call>{\"name\":", "\"x\"}</tool_c", "all> Done"}, want: []string{"content:Let me check. Done"}},
+		{name: "tool call after think", deltas: []string{"<think>plan</think><tool_call>{}</tool_call>"}, want: []string{"thinking:plan"}},
+		{name: "false alarm", deltas: []string{"a <t", "able> b <", "3"}, want: []string{"content:a <table> b <3"}},
+		{name: "partial tag at the end", deltas: []string{"a <thi"}, want: []string{"content:a <thi"}},
+		{name: "unclosed think", deltas: []string{"<think>still going </th"}, want: []string{"thinking:still going </th"}},
+		{name: "unclosed tool call", deltas: []string{"a<tool_call>{\"name\""}, want: []string{"content:a"}},
+		{name: "literal closing tag", deltas: []string{"a</think>b"}, want: []string{"content:a</think>b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitEvents(test.deltas...); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTagSplitterWithoutHandler(t *testing.T) {
+	splitter := newTagSplitter(nil)
+	splitter.feed("<think>a</think>b")
+	splitter.flush()
+}
+
+// streamingOllama returns an agent whose Ollama server answers every chat
+// request with lines as an NDJSON stream.
+func streamingOllama(t *testing.T, lines ...string) *Agent {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &Agent{Name: "Streaming", Model: Model{Name: "fake", ContextWindow: 4096}, Provider: &Provider{Type: "ollama", BaseUrl: server.URL}}
+}
+
+func TestOllamaStreamSplitsTags(t *testing.T) {
+	agent := streamingOllama(t,
+		`{"model":"fake","message":{"role":"assistant","content":"<thi"}}`,
+		`{"message":{"content":"nk>a plan</think>An"}}`,
+		`{"message":{"content":"swer<tool_call>{\"name\":\"x\","}}`,
+		`{"message":{"content":"\"arguments\":{}}</tool_call>"}}`,
+		`{"done":true,"done_reason":"stop","prompt_eval_count":3,"eval_count":4}`,
+	)
+	agent.RegisterTools(NewTool("function", "x", "does nothing", func(*ToolInvocation) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	}))
+	var events []string
+	var done *ChatResponse
+	response, err := NewChat(agent, nil).SendMessageStream("user", "hi", func(event *StreamEvent) {
+		switch event.Type {
+		case StreamToolCall:
+			events = append(events, "tool_call:"+event.ToolCall.Name)
+		case StreamDone:
+			done = event.Response
+			events = append(events, "done")
+		default:
+			events = append(events, string(event.Type)+":"+event.Delta)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined := strings.Join(events, "|"); joined != "thinking:a plan|content:An|content:swer|tool_call:x|done" || done != response {
+		t.Fatalf("got events %s", joined)
+	}
+	message := response.Message
+	if message.Role != "assistant" || message.Content != "Answer" || message.Thinking != "a plan" || len(message.ToolCalls) != 1 || message.ToolCalls[0].Name != "x" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+	if !response.Done || response.DoneReason != "stop" || response.PromptEvalCount != 3 || response.EvalCount != 4 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	agent := streamingOllama(t, `{"message":{"content":"a"}}`, `{"error":"out of memory"}`)
+	_, err := NewChat(agent, nil).SendMessageStream("user", "hi", nil)
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Fatalf("got %v, want the stream error", err)
+	}
+}
@@ -0,0 +1,167 @@
+package goAgent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ToolCall is a tool invocation requested by the model.
+// Result, Error and Duration are filled in once the call has been run; they
+// are not part of the wire format.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage // always valid JSON, normally an object
+	Result    map[string]interface{}
+	Error     string
+	Duration  time.Duration
+}
+
+// NewToolCall builds a tool call. Arguments may be a JSON object, a JSON
+// string holding an object (as OpenAI sends them) or empty.
+func NewToolCall(id, name string, arguments []byte) *ToolCall {
+	return &ToolCall{ID: id, Name: name, Arguments: normalizeArguments(arguments)}
+}
+
+// normalizeArguments returns arguments as valid JSON: empty input, including
+// an empty string, becomes {}, string-encoded JSON is unwrapped and anything
+// unparsable is kept as a JSON string.
+func normalizeArguments(arguments []byte) json.RawMessage {
+	arguments = bytes.TrimSpace(arguments)
+	if len(arguments) == 0 || bytes.Equal(arguments, []byte("null")) {
+		return json.RawMessage("{}")
+	}
+	if arguments[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(arguments, &encoded); err == nil {
+			if decoded := bytes.TrimSpace([]byte(encoded)); len(decoded) == 0 || json.Valid(decoded) {
+				return normalizeArguments(decoded)
+			}
+			return arguments
+		}
+	}
+	if json.Valid(arguments) {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, arguments); err == nil {
+			return compacted.Bytes()
+		}
+	}
+	quoted, _ := json.Marshal(string(arguments))
+	return quoted
+}
+
+// wireToolCall covers the tool call shapes of the supported wire formats:
+// Ollama and OpenAI nest name and arguments under "function", inline
+// <tool_call> tags carry them flat, and Anthropic calls its arguments "input".
+type wireToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function *struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	Input      json.RawMessage `json:"input,omitempty"`
+}
+
+// MarshalJSON writes the call in the Ollama/OpenAI shape, with the arguments as an object.
+func (tc *ToolCall) MarshalJSON() ([]byte, error) {
+	wire := map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":      tc.Name,
+			"arguments": normalizeArguments(tc.Arguments),
+		},
+	}
+	if tc.ID != "" {
+		wire["id"] = tc.ID
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON reads a call in any of the supported wire shapes.
+func (tc *ToolCall) UnmarshalJSON(data []byte) error {
+	var wire wireToolCall
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	tc.ID = wire.ID
+	switch {
+	case wire.Function != nil:
+		tc.Name = wire.Function.Name
+		tc.Arguments = normalizeArguments(wire.Function.Arguments)
+	case wire.Input != nil:
+		tc.Name = wire.Name
+		tc.Arguments = normalizeArguments(wire.Input)
+	case wire.Parameters != nil && wire.Arguments == nil:
+		tc.Name = wire.Name
+		tc.Arguments = normalizeArguments(wire.Parameters)
+	default:
+		tc.Name = wire.Name
+		tc.Arguments = normalizeArguments(wire.Arguments)
+	}
+	return nil
+}
+
+// Bind unmarshals the call's arguments into v.
+func (tc *ToolCall) Bind(v interface{}) error {
+	if err := json.Unmarshal(tc.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments for tool %s: %w", tc.Name, err)
+	}
+	return nil
+}
+
+// ArgumentMap returns the arguments decoded into a map, or an empty map when
+// they are not a JSON object.
+func (tc *ToolCall) ArgumentMap() map[string]interface{} {
+	arguments := map[string]interface{}{}
+	if err := json.Unmarshal(tc.Arguments, &arguments); err != nil || arguments == nil {
+		return map[string]interface{}{}
+	}
+	return arguments
+}
+
+// argumentObject returns the arguments if they form a JSON object, {} otherwise.
+func (tc *ToolCall) argumentObject() json.RawMessage {
+	arguments := normalizeArguments(tc.Arguments)
+	if arguments[0] != '{' {
+		return json.RawMessage("{}")
+	}
+	return arguments
+}
+
+// ToolInvocation is what a tool handler receives for a single call.
+type ToolInvocation struct {
+	Context context.Context // cancelled when the request that triggered the call is
+	Chat    *Chat           // a copy of the chat the call came from, taken as the call started; may be nil
+	Call    *ToolCall
+	Caller  string // name of the agent that made the call
+	Prompt  string // the user prompt the model was answering
+}
+
+// Runtime returns the runtime the call runs in: the calling chat's, else the
+// one carried by the request context, else DefaultRuntime.
+func (inv *ToolInvocation) Runtime() *Runtime {
+	if inv.Chat != nil && inv.Chat.Runtime != nil {
+		return inv.Chat.Runtime
+	}
+	return RuntimeFrom(inv.Context)
+}
+
+// Bind unmarshals the call's arguments into v.
+func (inv *ToolInvocation) Bind(v interface{}) error {
+	return inv.Call.Bind(v)
+}
+
+// Arguments returns the call's arguments decoded into a map.
+func (inv *ToolInvocation) Arguments() map[string]interface{} {
+	return inv.Call.ArgumentMap()
+}
+
+// ToolHandler implements a tool. The returned map is sent back to the model as the call's result.
+type ToolHandler func(inv *ToolInvocation) (map[string]interface{}, error)
@@ -0,0 +1,113 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToolCallUnmarshal(t *testing.T) {
+	tests := []struct {
+		name      string
+		wire      string
+		id        string
+		call      string
+		arguments string
+	}{
+		{name: "ollama", wire: `{"function":{"name":"search","arguments":{"q": "go"}}}`, call: "search", arguments: `{"q":"go"}`},
+		{name: "openai", wire: `{"id":"call_1","type":"function","function":{"name":"search","arguments":"{\"q\":\"go\"}"}}`, id: "call_1", call: "search", arguments: `{"q":"go"}`},
+		{name: "inline tag", wire: `{"name":"search","arguments":{"q":"go"}}`, call: "search", arguments: `{"q":"go"}`},
+		{name: "inline tag with parameters", wire: `{"name":"search","parameters":{"q":"go"}}`, call: "search", arguments: `{"q":"go"}`},
+		{name: "arguments win over parameters", wire: `{"name":"search","arguments":{"q":"go"},"parameters":{"q":"rust"}}`, call: "search", arguments: `{"q":"go"}`},
+		{name: "anthropic", wire: `{"id":"toolu_1","name":"search","input":{"q":"go"}}`, id: "toolu_1", call: "search", arguments: `{"q":"go"}`},
+		{name: "no arguments", wire: `{"function":{"name":"now"}}`, call: "now", arguments: `{}`},
+		{name: "null arguments", wire: `{"name":"now","arguments":null}`, call: "now", arguments: `{}`},
+		{name: "empty string arguments", wire: `{"function":{"name":"now","arguments":""}}`, call: "now", arguments: `{}`},
+		{name: "encoded twice", wire: `{"function":{"name":"search","arguments":"\"{\\\"q\\\":1}\""}}`, call: "search", arguments: `{"q":1}`},
+		{name: "unparsable string", wire: `{"function":{"name":"search","arguments":"{q: go"}}`, call: "search", arguments: `"{q: go"`},
+		{name: "array arguments", wire: `{"name":"sum","arguments":[1, 2]}`, call: "sum", arguments: `[1,2]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var call ToolCall
+			if err := json.Unmarshal([]byte(test.wire), &call); err != nil {
+				t.Fatal(err)
+			}
+			if call.ID != test.id || call.Name != test.call || string(call.Arguments) != test.arguments {
+				t.Fatalf("got %q %q %s", call.ID, call.Name, call.Arguments)
+			}
+			if !json.Valid(call.Arguments) {
+				t.Fatalf("arguments %s are not valid JSON", call.Arguments)
+			}
+
+			data, err := json.Marshal(&call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var again ToolCall
+			if err = json.Unmarshal(data, &again); err != nil {
+				t.Fatal(err)
+			}
+			if again.ID != call.ID || again.Name != call.Name || string(again.Arguments) != string(call.Arguments) {
+				t.Fatalf("%s read back as %+v", data, again)
+			}
+		})
+	}
+}
+
+func TestToolCallUnmarshalMalformed(t *testing.T) {
+	for _, wire := range []string{`[1]`, `"search"`, `{"function":"search"}`, `{"name":1}`, `{"function":{"name":"search","arguments":{}`} {
+		var call ToolCall
+		if err := json.Unmarshal([]byte(wire), &call); err == nil {
+			t.Errorf("%s was accepted as %+v", wire, call)
+		}
+	}
+}
+
+func TestToolCallMarshal(t *testing.T) {
+	tests := []struct {
+		call *ToolCall
+		want string
+	}{
+		{call: NewToolCall("", "now", nil), want: `{"function":{"arguments":{},"name":"now"},"type":"function"}`},
+		{call: NewToolCall("call_1", "search", []byte(`"{\"q\": \"go\"}"`)), want: `{"function":{"arguments":{"q":"go"},"name":"search"},"id":"call_1","type":"function"}`},
+		{call: &ToolCall{Name: "raw", Arguments: json.RawMessage(` {"a": 1} `), Result: map[string]interface{}{"x": 1}, Error: "hidden"}, want: `{"function":{"arguments":{"a":1},"name":"raw"},"type":"function"}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mustCompact(t, string(data)) != mustCompact(t, test.want) {
+			t.Errorf("%s marshalled to %s, want %s", test.call.Name, data, test.want)
+		}
+	}
+}
+
+// mustCompact returns data as compact JSON with sorted keys.
+func mustCompact(t *testing.T, data string) string {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatal(err)
+	}
+	compact, _ := json.Marshal(value)
+	return string(compact)
+}
+
+func TestToolCallArguments(t *testing.T) {
+	call := NewToolCall("", "list", []byte(`[1,2]`))
+	if len(call.ArgumentMap()) != 0 || string(call.argumentObject()) != `{}` {
+		t.Fatalf("non-object arguments gave %v and %s", call.ArgumentMap(), call.argumentObject())
+	}
+	call = NewToolCall("", "search", []byte(`{"q":"go","n":2}`))
+	if arguments := call.ArgumentMap(); arguments["q"] != "go" || arguments["n"] != float64(2) {
+		t.Fatalf("got %v", arguments)
+	}
+	var bound struct{ Q string }
+	if err := call.Bind(&bound); err != nil || bound.Q != "go" {
+		t.Fatalf("got %+v and %v", bound, err)
+	}
+	if err := NewToolCall("", "list", []byte(`[1]`)).Bind(&bound); err == nil {
+		t.Fatal("list arguments bound to a struct")
+	}
+}
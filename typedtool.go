@@ -0,0 +1,69 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// NewTypedTool builds a tool from a Go function. Its parameters are derived
+// from In's fields the same way SchemaFor derives a schema: json names,
+// description, enum and required tags. The validated arguments are decoded
+// into In, and fn's Out is sent back to the model. Out values that are not
+// JSON objects are sent as {"value": out}. In must be a struct.
+func NewTypedTool[In, Out any](name, description string, fn func(ctx context.Context, in In) (Out, error)) *Tool {
+	tool := NewTool("function", name, description, func(inv *ToolInvocation) (map[string]interface{}, error) {
+		var in In
+		if err := inv.Bind(&in); err != nil {
+			return nil, err
+		}
+		out, err := fn(inv.Context, in)
+		if err != nil {
+			return nil, err
+		}
+		return resultMap(out)
+	})
+	tool.Function.Parameters = ParametersFor[In]()
+	return tool
+}
+
+// ParametersFor derives tool parameters from the fields of the struct T.
+// Like SchemaFor it sets additionalProperties to false, so arguments that are
+// not fields of T are rejected.
+func ParametersFor[T any]() ToolParameters {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("goAgent: tool parameters must be a struct, got %s", t))
+	}
+
+	data, err := json.Marshal(SchemaFor[T]())
+	if err != nil {
+		panic(fmt.Sprintf("goAgent: failed to encode schema of %s: %v", t, err))
+	}
+	var parameters ToolParameters
+	if err = json.Unmarshal(data, &parameters); err != nil {
+		panic(fmt.Sprintf("goAgent: failed to decode schema of %s: %v", t, err))
+	}
+	return parameters
+}
+
+// resultMap converts a typed tool's result to the map sent to the model.
+func resultMap(out interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tool result: %w", err)
+	}
+	var result map[string]interface{}
+	if err = json.Unmarshal(data, &result); err == nil && result != nil {
+		return result, nil
+	}
+	var value interface{}
+	if err = json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode tool result: %w", err)
+	}
+	return map[string]interface{}{"value": value}, nil
+}
@@ -0,0 +1,77 @@
+package goAgent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type levelArgs struct {
+	Level int    `json:"level" enum:"1,2,3" description:"how hard to try"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+func TestTypedToolAcceptsIntEnumAndBytes(t *testing.T) {
+	var got levelArgs
+	tool := NewTypedTool("level", "sets the level", func(ctx context.Context, in levelArgs) (string, error) {
+		got = in
+		return "ok", nil
+	})
+	if tool.Function.Parameters.AdditionalProperties != false {
+		t.Fatalf("additionalProperties = %#v, want false", tool.Function.Parameters.AdditionalProperties)
+	}
+	if Type := tool.Function.Parameters.Properties["data"].Type; Type != "string" {
+		t.Fatalf("[]byte parameter has type %q, want string", Type)
+	}
+
+	call := &ToolCall{Name: "level", Arguments: json.RawMessage(`{"level": 2, "data": "aGVsbG8="}`)}
+	if _, err := tool.CallContext(context.Background(), call, nil); err != nil {
+		t.Fatalf("valid arguments rejected: %v", err)
+	}
+	if got.Level != 2 || string(got.Data) != "hello" {
+		t.Fatalf("decoded %+v", got)
+	}
+
+	for arguments, want := range map[string]string{
+		`{"level": 4}`:             "not one of 1, 2, 3",
+		`{"level": 1, "extra": 1}`: "extra: unknown field",
+	} {
+		_, err := tool.CallContext(context.Background(), &ToolCall{Name: "level", Arguments: json.RawMessage(arguments)}, nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error containing %q", arguments, err, want)
+		}
+	}
+}
+
+func TestEnumContainsLegacyStringEnums(t *testing.T) {
+	property := NewToolParameterProperty("integer", "", []string{"1", "2"}, false)
+	if _, problems := property.check("level", "2"); len(problems) > 0 {
+		t.Fatalf("string enum on an integer rejected a valid value: %v", problems)
+	}
+	if _, problems := property.check("level", float64(3)); len(problems) == 0 || !strings.Contains(problems[0].Message, "not one of 1, 2") {
+		t.Fatalf("got %v", problems)
+	}
+}
+
+func TestValidateArgumentsAdditionalProperties(t *testing.T) {
+	parameters := *NewToolParameters("object")
+	parameters.AddProperty("query", "string", "", nil, true)
+	parameters.SetProperty("filters", NewObjectProperty("").
+		WithProperty("site", NewStringProperty(""), false), false)
+	parameters.Properties["filters"].AdditionalProperties = false
+
+	if _, problems := parameters.ValidateArguments(json.RawMessage(`{"query": "go", "extra": 1}`)); len(problems) > 0 {
+		t.Fatalf("unknown fields are allowed without additionalProperties: %v", problems)
+	}
+	_, problems := parameters.ValidateArguments(json.RawMessage(`{"query": "go", "filters": {"site": "x", "other": 1}}`))
+	if len(problems) != 1 || problems[0].Field != "filters.other" {
+		t.Fatalf("got %v", problems)
+	}
+
+	parameters.AdditionalProperties = NewIntegerProperty("")
+	coerced, problems := parameters.ValidateArguments(json.RawMessage(`{"query": "go", "limit": "5"}`))
+	if len(problems) > 0 || !strings.Contains(string(coerced), `"limit":5`) {
+		t.Fatalf("got %s %v", coerced, problems)
+	}
+}
@@ -0,0 +1,305 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ArgumentError reports tool arguments that do not match the tool's parameters.
+// It is sent back to the model as a structured tool result so it can retry the call.
+type ArgumentError struct {
+	Tool     string
+	Problems []ArgumentProblem
+}
+
+// ArgumentProblem is a single invalid or missing argument.
+type ArgumentProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ArgumentError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %s", problem.Field, problem.Message))
+	}
+	return fmt.Sprintf("invalid arguments for tool %s: %s", e.Tool, strings.Join(problems, "; "))
+}
+
+// toolResult is the tool message content reporting the error to the model.
+func (e *ArgumentError) toolResult() map[string]interface{} {
+	return map[string]interface{}{
+		"error":             e.Error(),
+		"invalid_arguments": e.Problems,
+		"hint":              fmt.Sprintf("Call %s again with arguments that match its parameters.", e.Tool),
+	}
+}
+
+// ValidateArguments checks arguments against the parameters: required fields
+// must be present, and values must have the declared type and satisfy the
+// property's enum, bounds, pattern, items and nested properties. Values that
+// safely convert to the declared type, such as the numeric string "2" for an
+// integer or a JSON-encoded list for an array, are converted, and missing
+// fields with a default get it. Fields not in the parameters are checked
+// against AdditionalProperties. A pattern that does not compile is reported as
+// a problem with the value. The converted arguments are returned along with any problems.
+func (toolParameters ToolParameters) ValidateArguments(arguments json.RawMessage) (json.RawMessage, []ArgumentProblem) {
+	var values map[string]interface{}
+	if err := json.Unmarshal(normalizeArguments(arguments), &values); err != nil || values == nil {
+		return arguments, []ArgumentProblem{{Field: "$", Message: "arguments must be a JSON object"}}
+	}
+
+	problems := checkObject("", values, toolParameters.Properties, toolParameters.requiredNames(), toolParameters.AdditionalProperties)
+
+	coerced, err := json.Marshal(values)
+	if err != nil {
+		return arguments, append(problems, ArgumentProblem{Field: "$", Message: err.Error()})
+	}
+	return coerced, problems
+}
+
+// requiredNames merges the required list with properties flagged as required.
+func (toolParameters ToolParameters) requiredNames() []string {
+	return mergeRequired(toolParameters.Required, toolParameters.Properties)
+}
+
+func mergeRequired(required []string, properties map[string]*ToolParameterProperty) []string {
+	merged := append(make([]string, 0, len(required)), required...)
+	var flagged []string
+	for name, property := range properties {
+		if property != nil && property.Required && !slices.Contains(merged, name) {
+			flagged = append(flagged, name)
+		}
+	}
+	sort.Strings(flagged)
+	return append(merged, flagged...)
+}
+
+// checkObject validates the fields of values in place. Unknown fields are
+// checked against additional, the object's additionalProperties: false
+// rejects them, a schema validates them and anything else passes them through.
+func checkObject(path string, values map[string]interface{}, properties map[string]*ToolParameterProperty, required []string, additional interface{}) []ArgumentProblem {
+	var problems []ArgumentProblem
+	for _, name := range required {
+		if _, ok := values[name]; !ok {
+			problems = append(problems, ArgumentProblem{Field: fieldPath(path, name), Message: "required field is missing"})
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property := properties[name]
+		if property == nil {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			if property.Default != nil {
+				values[name] = property.Default
+			}
+			continue
+		}
+		value, propertyProblems := property.check(fieldPath(path, name), value)
+		values[name] = value
+		problems = append(problems, propertyProblems...)
+	}
+
+	schema, allowed := additionalSchema(additional)
+	for _, name := range sortedKeys(values) {
+		if _, known := properties[name]; known {
+			continue
+		}
+		switch {
+		case !allowed:
+			problems = append(problems, ArgumentProblem{Field: fieldPath(path, name), Message: "unknown field"})
+		case schema != nil:
+			value, propertyProblems := schema.check(fieldPath(path, name), values[name])
+			values[name] = value
+			problems = append(problems, propertyProblems...)
+		}
+	}
+	return problems
+}
+
+// additionalSchema reads an additionalProperties value: whether unknown fields
+// are allowed, and the schema they must match if there is one.
+func additionalSchema(additional interface{}) (*ToolParameterProperty, bool) {
+	switch v := additional.(type) {
+	case bool:
+		return nil, v
+	case *ToolParameterProperty:
+		return v, true
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, true
+		}
+		var schema ToolParameterProperty
+		if err = json.Unmarshal(data, &schema); err != nil {
+			return nil, true
+		}
+		return &schema, true
+	}
+	return nil, true
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// check validates a single value against the property, coercing it where safe.
+func (property *ToolParameterProperty) check(field string, value interface{}) (interface{}, []ArgumentProblem) {
+	problem := func(format string, args ...interface{}) []ArgumentProblem {
+		return []ArgumentProblem{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(property.OneOf) > 0 {
+		matched := false
+		for _, option := range property.OneOf {
+			if coerced, problems := option.check(field, value); len(problems) == 0 {
+				value, matched = coerced, true
+				break
+			}
+		}
+		if !matched {
+			return value, problem("does not match any of the %d allowed schemas", len(property.OneOf))
+		}
+	}
+
+	Type := property.schemaType()
+	if Type != "" {
+		coerced, ok := coerceValue(value, Type)
+		if !ok {
+			return value, problem("expected %s, got %s", Type, jsonTypeName(value))
+		}
+		value = coerced
+	}
+	if len(property.Enum) > 0 && !enumContains(property.Enum, value) {
+		return value, problem("%q is not one of %s", fmt.Sprint(value), enumList(property.Enum))
+	}
+
+	switch typed := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if property.MinLength != nil && length < *property.MinLength {
+			return value, problem("must be at least %d characters", *property.MinLength)
+		}
+		if property.MaxLength != nil && length > *property.MaxLength {
+			return value, problem("must be at most %d characters", *property.MaxLength)
+		}
+		if property.Pattern != "" {
+			pattern, err := regexp.Compile(property.Pattern)
+			if err != nil {
+				return value, problem("cannot be checked, the tool's pattern %s is invalid: %v", property.Pattern, err)
+			}
+			if !pattern.MatchString(typed) {
+				return value, problem("must match the pattern %s", property.Pattern)
+			}
+		}
+	case float64:
+		if property.Minimum != nil && typed < *property.Minimum {
+			return value, problem("must be at least %v", *property.Minimum)
+		}
+		if property.Maximum != nil && typed > *property.Maximum {
+			return value, problem("must be at most %v", *property.Maximum)
+		}
+	case []interface{}:
+		if property.MinItems != nil && len(typed) < *property.MinItems {
+			return value, problem("must have at least %d items", *property.MinItems)
+		}
+		if property.MaxItems != nil && len(typed) > *property.MaxItems {
+			return value, problem("must have at most %d items", *property.MaxItems)
+		}
+		if property.Items == nil {
+			break
+		}
+		var problems []ArgumentProblem
+		for i, item := range typed {
+			coerced, itemProblems := property.Items.check(fmt.Sprintf("%s[%d]", field, i), item)
+			typed[i] = coerced
+			problems = append(problems, itemProblems...)
+		}
+		return typed, problems
+	case map[string]interface{}:
+		return typed, checkObject(field, typed, property.Properties, property.requiredNames(), property.AdditionalProperties)
+	}
+	return value, nil
+}
+
+// coerceValue returns value as the given JSON Schema type, converting the
+// usual mistakes models make: numbers and booleans sent as strings, arrays and
+// objects sent as (possibly single-quoted) JSON strings, a lone string where a
+// list was expected.
+func coerceValue(value interface{}, Type string) (interface{}, bool) {
+	if schemaTypeMatches(Type, value) {
+		return value, true
+	}
+	text, isString := value.(string)
+	text = strings.TrimSpace(text)
+
+	switch Type {
+	case "integer":
+		if isString {
+			if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return float64(number), true
+			}
+		}
+	case "number":
+		if isString {
+			if number, err := strconv.ParseFloat(text, 64); err == nil {
+				return number, true
+			}
+		}
+	case "boolean":
+		if isString {
+			if boolean, err := strconv.ParseBool(text); err == nil {
+				return boolean, true
+			}
+		}
+	case "string":
+		switch value.(type) {
+		case float64, bool:
+			return fmt.Sprint(value), true
+		}
+	case "array", "object":
+		if !isString {
+			break
+		}
+		if decoded, ok := decodeEmbeddedJSON(text); ok && schemaTypeMatches(Type, decoded) {
+			return decoded, true
+		}
+		if Type == "array" && text != "" && !strings.HasPrefix(text, "[") {
+			return []interface{}{text}, true
+		}
+	}
+	return value, false
+}
+
+// decodeEmbeddedJSON decodes JSON that was sent as a string, including
+// Python-style single-quoted lists and JSON encoded twice.
+func decodeEmbeddedJSON(text string) (interface{}, bool) {
+	if strings.HasPrefix(text, "['") && strings.HasSuffix(text, "']") {
+		text = strings.ReplaceAll(text, `'`, `"`)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		return nil, false
+	}
+	if nested, ok := decoded.(string); ok {
+		return decodeEmbeddedJSON(strings.TrimSpace(nested))
+	}
+	return decoded, true
+}
@@ -0,0 +1,119 @@
+package goAgent
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		Type  string
+		value interface{}
+		want  string // the coerced value as JSON, empty when it is rejected
+	}{
+		{"integer", float64(2), `2`},
+		{"integer", "2", `2`},
+		{"integer", " 7 ", `7`},
+		{"integer", "2.5", ``},
+		{"integer", float64(2.5), ``},
+		{"number", "2.5", `2.5`},
+		{"number", "many", ``},
+		{"boolean", "true", `true`},
+		{"boolean", "yes", ``},
+		{"string", float64(3), `"3"`},
+		{"string", true, `"true"`},
+		{"string", []interface{}{"a"}, ``},
+		{"array", `["a","b"]`, `["a","b"]`},
+		{"array", `['a', 'b']`, `["a","b"]`},
+		{"array", `"[1,2]"`, `[1,2]`},
+		{"array", "lonely", `["lonely"]`},
+		{"array", "[broken", ``},
+		{"array", "", ``},
+		{"object", `{"a":1}`, `{"a":1}`},
+		{"object", `[1]`, ``},
+		{"object", float64(1), ``},
+	}
+	for _, test := range tests {
+		coerced, ok := coerceValue(test.value, test.Type)
+		if ok != (test.want != "") {
+			t.Errorf("coerceValue(%#v, %s) accepted = %t, want %t", test.value, test.Type, ok, test.want != "")
+			continue
+		}
+		if got, _ := json.Marshal(coerced); ok && string(got) != test.want {
+			t.Errorf("coerceValue(%#v, %s) = %s, want %s", test.value, test.Type, got, test.want)
+		}
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	parameters := *NewToolParameters("object")
+	parameters.SetProperty("count", NewIntegerProperty("").WithRange(1, 10), true)
+	parameters.SetProperty("name", NewStringProperty("").WithLength(2, 5).WithPattern(`^[a-z]+$`), false)
+	parameters.SetProperty("unit", NewStringProperty("").WithEnum("km", "mi").WithDefault("km"), false)
+	parameters.SetProperty("tags", NewArrayProperty("", NewIntegerProperty("")).WithItemCount(1, 2), false)
+	parameters.SetProperty("point", NewObjectProperty("").WithProperty("x", NewNumberProperty(""), true), false)
+
+	tests := []struct {
+		name      string
+		arguments string
+		want      string // the coerced arguments
+		problems  string // the fields with problems
+	}{
+		{name: "valid", arguments: `{"count":3}`, want: `{"count":3,"unit":"km"}`},
+		{name: "coerced", arguments: `{"count":"3","tags":"[\"1\",2]","point":"{\"x\":\"1.5\"}"}`, want: `{"count":3,"point":{"x":1.5},"tags":[1,2],"unit":"km"}`},
+		{name: "string arguments", arguments: `"{\"count\":3,\"unit\":\"mi\"}"`, want: `{"count":3,"unit":"mi"}`},
+		{name: "missing", arguments: `{}`, problems: "count"},
+		{name: "not an object", arguments: `[1]`, problems: "$"},
+		{name: "out of range", arguments: `{"count":11}`, problems: "count"},
+		{name: "too short and wrong enum", arguments: `{"count":1,"name":"a","unit":"m"}`, problems: "name,unit"},
+		{name: "pattern", arguments: `{"count":1,"name":"ABC"}`, problems: "name"},
+		{name: "items", arguments: `{"count":1,"tags":[1,"x"]}`, problems: "tags[1]"},
+		{name: "item count", arguments: `{"count":1,"tags":[1,2,3]}`, problems: "tags"},
+		{name: "nested", arguments: `{"count":1,"point":{}}`, problems: "point.x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			coerced, problems := parameters.ValidateArguments(json.RawMessage(test.arguments))
+			var fields []string
+			for _, problem := range problems {
+				fields = append(fields, problem.Field)
+			}
+			if got := strings.Join(fields, ","); got != test.problems {
+				t.Fatalf("got problems %v, want fields %s", problems, test.problems)
+			}
+			if test.want != "" && string(coerced) != test.want {
+				t.Fatalf("got %s, want %s", coerced, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateArgumentsOneOf(t *testing.T) {
+	parameters := *NewToolParameters("object")
+	parameters.SetProperty("id", &ToolParameterProperty{OneOf: []*ToolParameterProperty{NewIntegerProperty(""), NewStringProperty("").WithPattern(`^id-`)}}, true)
+
+	if coerced, problems := parameters.ValidateArguments(json.RawMessage(`{"id":"7"}`)); len(problems) > 0 || string(coerced) != `{"id":7}` {
+		t.Fatalf("got %s %v", coerced, problems)
+	}
+	if _, problems := parameters.ValidateArguments(json.RawMessage(`{"id":"id-7"}`)); len(problems) > 0 {
+		t.Fatalf("got %v", problems)
+	}
+	if _, problems := parameters.ValidateArguments(json.RawMessage(`{"id":"seven"}`)); len(problems) != 1 || !strings.Contains(problems[0].Message, "2 allowed schemas") {
+		t.Fatalf("got %v", problems)
+	}
+}
+
+func TestInvalidPatternIsAnArgumentError(t *testing.T) {
+	tool := NewTool("function", "lookup", "looks a name up", func(*ToolInvocation) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+	tool.Function.Parameters.SetProperty("name", NewStringProperty("").WithPattern(`[a-z`), true)
+
+	_, err := tool.Call(NewToolCall("", "lookup", []byte(`{"name":"abc"}`)), nil)
+	var argumentErr *ArgumentError
+	if !errors.As(err, &argumentErr) || len(argumentErr.Problems) != 1 || !strings.Contains(argumentErr.Problems[0].Message, "pattern [a-z is invalid") {
+		t.Fatalf("got %v, want the invalid pattern reported", err)
+	}
+}